@@ -24,58 +24,606 @@ SOFTWARE.
 package main
 
 import (
-	_ "embed"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
-
-	"log"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
 	"github.com/barasher/go-exiftool"
+	"github.com/lluissm/media-renamer/internal/cache"
 	"github.com/lluissm/media-renamer/internal/config"
-	"github.com/lluissm/media-renamer/internal/options"
+	"github.com/lluissm/media-renamer/internal/interactive"
+	"github.com/lluissm/media-renamer/internal/journal"
+	"github.com/lluissm/media-renamer/internal/logging"
+	opts "github.com/lluissm/media-renamer/internal/options"
+	"github.com/lluissm/media-renamer/internal/output"
 	"github.com/lluissm/media-renamer/internal/process"
+	"github.com/lluissm/media-renamer/internal/progress"
+	"github.com/lluissm/media-renamer/internal/report"
+	"github.com/lluissm/media-renamer/internal/suggest"
+	"github.com/lluissm/media-renamer/internal/watch"
+	"github.com/lluissm/media-renamer/pkg/mediarenamer"
 )
 
-//go:embed config.yml
-var defaultConfigFile []byte
-
 var version string = "development"
 
 func main() {
+	os.Exit(realMain())
+}
+
+// realMain runs the command of the cli args and returns the exit status of
+// the process. Errors are returned up to it, instead of exiting, so that
+// the journal, cache and log files are always closed.
+func realMain() int {
 	// Parse cli flags and arguments
-	options, err := options.Parse(os.Args)
+	options, err := opts.Parse(os.Args)
+	if err != nil {
+		return exitStatus(slog.Default(), fatal("Could not parse the cli args", err))
+	}
+
+	logger, closeLog, err := newLogger(options.LogFile, options.LogLevel, options.LogFormat)
 	if err != nil {
-		log.Fatalf("could not parse the cli args: %s", err.Error())
+		return exitStatus(slog.Default(), fatal("Could not create the logger", err))
 	}
-	if options.ShowVersion {
+	defer closeLog()
+
+	// SIGINT and SIGTERM cancel the processing, which stops after the file
+	// being renamed and still writes the output, reports and journal. A
+	// second signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	switch options.Command {
+	case opts.CommandHelp:
+		return 0
+	case opts.CommandVersion:
 		fmt.Printf("version: %s\n", version)
-		os.Exit(0)
+	case opts.CommandConfigShow:
+		err = showConfig(options.Paths[0], options.CustomConfigPath, options.ResolvedConfig)
+	case opts.CommandConfigSuggest:
+		err = suggestConfig(options.Paths[0], options.Samples)
+	case opts.CommandCachePrune:
+		err = pruneCache()
+	case opts.CommandUndo:
+		err = undo(ctx, logger, options)
+	case opts.CommandWatch:
+		// Watching until interrupted is the normal end of watch
+		return exitStatus(logger, watchFolder(ctx, logger, options))
+	case opts.CommandInspect:
+		err = inspect(ctx, logger, options)
+	default:
+		err = run(ctx, logger, options)
+	}
+
+	var fe *fatalError
+	if ctx.Err() != nil && !errors.As(err, &fe) {
+		logger.Warn("Interrupted, the remaining files were not processed")
+		return 130
+	}
+	return exitStatus(logger, err)
+}
+
+// run processes the folders and files of the rename, plan and verify
+// commands and returns errFailed if anything failed
+func run(ctx context.Context, logger *slog.Logger, options *opts.Options) error {
+	paths, err := collectPaths(options)
+	if err != nil {
+		return err
+	}
+
+	// Machine readable output goes to stdout, logs to stderr
+	out, err := output.New(options.Output, os.Stdout)
+	if err != nil {
+		return fatal("Error creating output", err)
+	}
+
+	// Only rename actually renames files, recording them in a journal
+	dryRun := options.DryRun || options.Command != opts.CommandRename
+	renamer, closeJournal, err := newRenamer(logger, options.Journal, dryRun)
+	if err != nil {
+		return err
+	}
+	defer closeJournal()
+
+	// Dates extracted in previous runs are reused for unchanged files
+	dateCache, closeCache := newCache(logger, options.NoCache)
+	defer closeCache()
+
+	// Progress is displayed in stderr, so that it does not mix with the
+	// output, after a fast pre-walk to know the number of files
+	var counts *mediarenamer.Progress
+	if !options.Quiet {
+		counts = &mediarenamer.Progress{}
+	}
+	// The results are written to the output and kept for the reports as
+	// they are notified. In interactive mode the renames are only planned
+	// and applied once confirmed.
+	rec := &recorder{
+		logger:   logger,
+		out:      out,
+		keep:     len(options.Reports) > 0,
+		verify:   options.Command == opts.CommandVerify,
+		planning: options.Interactive,
+	}
+	engine, err := newEngine(logger, options,
+		mediarenamer.WithFilesystem(renamer),
+		mediarenamer.WithCache(dateCache),
+		mediarenamer.WithProgress(counts),
+		mediarenamer.WithObserver(rec))
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	var display *progress.Display
+	if counts != nil {
+		count, _ := engine.Discover(ctx, paths...)
+		counts.Discovered.Add(int64(count))
+		display = progress.Start(os.Stderr, progress.IsTerminal(os.Stderr), counts)
+	}
+
+	req := mediarenamer.Request{Paths: paths, DryRun: dryRun || options.Interactive}
+	failed := false
+	err = engine.Process(ctx, req, nil)
+	if err != nil && ctx.Err() == nil {
+		logger.Error("Error processing", "error", err)
+		failed = true
+	}
+
+	if display != nil {
+		display.Stop()
+	}
+
+	if options.Interactive {
+		rec.planning = false
+		accepted, skipped, err := confirm(ctx, rec.plan)
+		if err != nil {
+			logger.Error("Error reading confirmation", "error", err)
+			failed = true
+		}
+		// The applied renames are notified to the recorder, the ones that
+		// are not applied (dry run or interruption) stay planned
+		if !dryRun {
+			accepted, err = engine.Apply(ctx, accepted)
+			if err != nil && ctx.Err() == nil {
+				logger.Error("Error applying the renames", "error", err)
+				failed = true
+			}
+		}
+		for _, res := range append(accepted, skipped...) {
+			if res.Status == mediarenamer.StatusPlanned || res.Status == mediarenamer.StatusSkipped {
+				rec.record(res)
+			}
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		logger.Error("Error writing output", "error", err)
+		failed = true
+	}
+	writeReports(logger, options.Reports, rec.results, &failed)
+	if rec.mismatches > 0 {
+		logger.Warn("Files not named after their date", "count", rec.mismatches)
+		failed = true
+	}
+	return failure(failed)
+}
+
+// watchFolder renames the files added to a folder until the process is
+// interrupted or terminated
+func watchFolder(ctx context.Context, logger *slog.Logger, options *opts.Options) error {
+	root, err := filepath.Abs(options.Paths[0])
+	if err != nil {
+		return fatal("Error resolving path", err)
+	}
+
+	out, err := output.New(options.Output, os.Stdout)
+	if err != nil {
+		return fatal("Error creating output", err)
+	}
+
+	renamer, closeJournal, err := newRenamer(logger, options.Journal, options.DryRun)
+	if err != nil {
+		return err
+	}
+	defer closeJournal()
+	dateCache, closeCache := newCache(logger, options.NoCache)
+	defer closeCache()
+
+	rec := &recorder{logger: logger, out: out, keep: len(options.Reports) > 0}
+	engine, err := newEngine(logger, options,
+		mediarenamer.WithFilesystem(renamer),
+		mediarenamer.WithCache(dateCache),
+		mediarenamer.WithObserver(rec))
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	skipDir, err := engine.DirSkipper(root)
+	if err != nil {
+		return fatal("Error loading configuration", err)
+	}
+
+	failed := false
+	watchOptions := watch.Options{Stable: options.Stable, Logger: logger, SkipDir: skipDir}
+	err = watch.Run(ctx, root, watchOptions, func(paths []string) []string {
+		req := mediarenamer.Request{Root: root, Paths: paths, DryRun: options.DryRun}
+		renamed := []string{}
+		err := engine.Process(ctx, req, func(res mediarenamer.Result) {
+			if res.Status == mediarenamer.StatusRenamed {
+				renamed = append(renamed, res.NewPath)
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			logger.Error("Error processing", "path", root, "error", err)
+		}
+		return renamed
+	})
+	if err != nil {
+		logger.Error("Error watching", "path", root, "error", err)
+		failed = true
+	}
+
+	if err := out.Close(); err != nil {
+		logger.Error("Error writing output", "error", err)
+		failed = true
+	}
+	writeReports(logger, options.Reports, rec.results, &failed)
+	return failure(failed)
+}
+
+// inspect displays, for each file, how its date is obtained from the metadata
+func inspect(ctx context.Context, logger *slog.Logger, options *opts.Options) error {
+	paths, err := collectPaths(options)
+	if err != nil {
+		return err
+	}
+
+	out, err := output.NewInspectionWriter(options.Output, os.Stdout)
+	if err != nil {
+		return fatal("Error creating output", err)
+	}
+
+	engine, err := newEngine(logger, options)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	failed := false
+	err = engine.Inspect(ctx, options.AllDates, func(ins mediarenamer.Inspection) {
+		if err := out.Inspection(ins); err != nil {
+			logger.Error("Error writing output", "error", err)
+		}
+		failed = failed || ins.Error != ""
+	}, paths...)
+	if err != nil && ctx.Err() == nil {
+		logger.Error("Error processing", "error", err)
+		failed = true
+	}
+	if err := out.Close(); err != nil {
+		logger.Error("Error writing output", "error", err)
+		failed = true
+	}
+	return failure(failed)
+}
+
+// newEngine returns the renaming engine configured in the command line
+func newEngine(logger *slog.Logger, options *opts.Options, extra ...mediarenamer.Option) (*mediarenamer.Renamer, error) {
+	engineOptions := []mediarenamer.Option{
+		mediarenamer.WithLogger(logger),
+		mediarenamer.WithConfigFile(options.CustomConfigPath),
+		mediarenamer.WithUserConfig(),
+		mediarenamer.WithHooks(),
+		mediarenamer.WithExclude(options.Exclude...),
+		mediarenamer.WithMaxDepth(options.MaxDepth),
+	}
+	if options.TrustNames {
+		engineOptions = append(engineOptions, mediarenamer.WithTrustNames())
+	}
+	if options.SetMtime || options.SetAtime {
+		engineOptions = append(engineOptions, mediarenamer.WithTimes(mediarenamer.Times{
+			Mtime:     options.SetMtime,
+			Atime:     options.SetAtime,
+			Unchanged: options.SetTimesNamed,
+		}))
+	}
+	if options.WriteMetadata {
+		engineOptions = append(engineOptions, mediarenamer.WithMetadataWriting(options.MetadataBackup))
+	}
+	if options.WriteShifted {
+		engineOptions = append(engineOptions, mediarenamer.WithShiftWriting())
+	}
+	if options.Shift != 0 {
+		engineOptions = append(engineOptions, mediarenamer.WithShift(options.Shift))
+	}
+	for _, ref := range options.SyncRefs {
+		engineOptions = append(engineOptions, mediarenamer.WithSyncReference(ref.Reference, ref.Target))
+	}
+	if options.OnCollision != "" {
+		engineOptions = append(engineOptions, mediarenamer.WithCollisionPolicy(mediarenamer.CollisionPolicy(options.OnCollision)))
+	}
+	engine, err := mediarenamer.New(append(engineOptions, extra...)...)
+	if err != nil {
+		return nil, fatal("Error creating the renaming engine", err)
 	}
+	return engine, nil
+}
 
-	// Load configuration
-	var configFile = defaultConfigFile
-	if options.CustomConfigPath != "" {
-		customConfig, err := os.ReadFile(options.CustomConfigPath)
+// undo reverts the renames recorded in a journal, the latest one by default
+func undo(ctx context.Context, logger *slog.Logger, options *opts.Options) error {
+	path := options.Journal
+	if path == "" {
+		dir, err := journal.Dir()
 		if err != nil {
-			log.Fatalf("Could not load custom config file %s", options.CustomConfigPath)
+			return fatal("Error finding the journal folder", err)
+		}
+		if path, err = journal.Latest(dir); err != nil {
+			return fatal("Error finding the latest journal", err, "path", dir)
 		}
-		configFile = customConfig
 	}
-	cfg, err := config.LoadConfig(configFile)
+
+	out, err := output.New(options.Output, os.Stdout)
 	if err != nil {
-		log.Fatalf("Error loading configuration from file: %v\n", err)
+		return fatal("Error creating output", err)
+	}
+
+	results, err := journal.Undo(ctx, path, mediarenamer.OSFilesystem{}, logger)
+	failed := err != nil
+	if err != nil && ctx.Err() == nil {
+		logger.Error("Error undoing journal", "path", path, "error", err)
+	}
+	for _, res := range results {
+		if err := out.Result(res); err != nil {
+			logger.Error("Error writing output", "error", err)
+		}
+		failed = failed || res.Status == process.StatusFailed
+	}
+	if err := out.Close(); err != nil {
+		logger.Error("Error writing output", "error", err)
+		failed = true
+	}
+	writeReports(logger, options.Reports, results, &failed)
+	return failure(failed)
+}
+
+// confirm asks for the confirmation of the planned renames, skipping all of
+// them if ctx is done before the user finishes
+func confirm(ctx context.Context, plan []mediarenamer.Result) ([]mediarenamer.Result, []mediarenamer.Result, error) {
+	type confirmation struct {
+		accepted, skipped []mediarenamer.Result
+		err               error
 	}
+	done := make(chan confirmation, 1)
+	go func() {
+		accepted, skipped, err := interactive.Confirm(os.Stdin, os.Stderr, plan)
+		done <- confirmation{accepted, skipped, err}
+	}()
 
-	// Initialize exifTool
+	select {
+	case c := <-done:
+		return c.accepted, c.skipped, c.err
+	case <-ctx.Done():
+		skipped := []mediarenamer.Result{}
+		for _, res := range plan {
+			res.Status = mediarenamer.StatusSkipped
+			skipped = append(skipped, res)
+		}
+		return nil, skipped, nil
+	}
+}
+
+// newRenamer returns the filesystem of a run and the function that closes
+// its journal. Renames are recorded in a journal unless it is a dry run.
+func newRenamer(logger *slog.Logger, journalPath string, dryRun bool) (mediarenamer.Filesystem, func(), error) {
+	fsys := mediarenamer.OSFilesystem{}
+	if dryRun {
+		return fsys, func() {}, nil
+	}
+	j, err := createJournal(journalPath)
+	if err != nil {
+		return nil, nil, fatal("Error creating journal", err)
+	}
+	return &journal.Renamer{FS: fsys, Journal: j}, func() {
+		if err := j.Close(); err != nil {
+			logger.Error("Error closing journal", "path", j.Path(), "error", err)
+		}
+	}, nil
+}
+
+// newCache opens the cache of extracted dates at its default path and
+// returns it with the function that closes it. Runs go on without cache if
+// it is disabled or cannot be opened.
+func newCache(logger *slog.Logger, disabled bool) (mediarenamer.Cache, func()) {
+	if disabled {
+		return nil, func() {}
+	}
+	path, err := cache.DefaultPath()
+	if err != nil {
+		logger.Warn("Could not open the cache, all files will be extracted", "error", err)
+		return nil, func() {}
+	}
+	c, err := cache.Open(path)
+	if err != nil {
+		logger.Warn("Could not open the cache, all files will be extracted", "path", path, "error", err)
+		return nil, func() {}
+	}
+	return c, func() {
+		if err := c.Close(); err != nil {
+			logger.Error("Error closing cache", "path", path, "error", err)
+		}
+	}
+}
+
+// pruneCache removes the cached dates of files that were deleted or changed
+func pruneCache() error {
+	path, err := cache.DefaultPath()
+	if err != nil {
+		return fatal("Error finding the cache", err)
+	}
+	removed, err := cache.Prune(path)
+	if err != nil {
+		return fatal("Error pruning the cache", err, "path", path)
+	}
+	fmt.Printf("Removed %d entries from %s\n", removed, path)
+	return nil
+}
+
+// createJournal creates the journal at path or, if empty, a new one in the
+// default journal folder
+func createJournal(path string) (*journal.Journal, error) {
+	if path == "" {
+		dir, err := journal.Dir()
+		if err != nil {
+			return nil, err
+		}
+		path = journal.NewPath(dir)
+	}
+	return journal.Create(path)
+}
+
+// collectPaths returns the folders and files to process
+func collectPaths(options *opts.Options) ([]string, error) {
+	paths := options.Paths
+	if options.FilesFrom != "" {
+		listed, err := readFileList(options.FilesFrom)
+		if err != nil {
+			return nil, fatal("Error reading the list of files", err, "path", options.FilesFrom)
+		}
+		paths = append(paths, listed...)
+	}
+	return paths, nil
+}
+
+// writeReports writes the results to the report files, setting failed on error
+func writeReports(logger *slog.Logger, paths []string, results []mediarenamer.Result, failed *bool) {
+	for _, path := range paths {
+		if err := report.Write(path, results); err != nil {
+			logger.Error("Error writing report", "path", path, "error", err)
+			*failed = true
+		}
+	}
+}
+
+// showConfig prints the default configuration or, if resolved, the
+// effective one for path with the layer each setting comes from
+func showConfig(path, customConfigPath string, resolved bool) error {
+	layers := []config.Layer{config.DefaultLayer()}
+	if resolved {
+		var err error
+		layers, err = config.Layers(path, customConfigPath, true)
+		if err != nil {
+			return fatal("Error loading configuration", err)
+		}
+	}
+	cfg, err := config.Resolve(layers...)
+	if err != nil {
+		return fatal("Error loading configuration from file", err)
+	}
+	if err := cfg.WriteResolved(os.Stdout); err != nil {
+		return fatal("Error writing configuration", err)
+	}
+	return nil
+}
+
+// suggestConfig prints the file types suggested from the metadata of the files in dir
+func suggestConfig(dir string, samples int) error {
 	et, err := exiftool.NewExiftool()
 	if err != nil {
-		log.Fatalf("Error intializing exiftool: %v\n", err)
+		return fatal("Error intializing exiftool", err)
 	}
 	defer et.Close()
 
-	// Process folder
-	path := options.Path
-	if err := process.Folder(et, cfg, path, options.Verbose); err != nil {
-		log.Fatalf("Error processing folder %s: %v\n", path, err)
+	suggestions, err := suggest.Suggest(et, dir, samples)
+	if err != nil {
+		return fatal("Error sampling files", err, "path", dir)
+	}
+	suggest.Write(os.Stdout, suggestions)
+	return nil
+}
+
+// newLogger returns the logger configured in the command line, writing to
+// stderr or to path if set, and the function that closes its file
+func newLogger(path, level, format string) (*slog.Logger, func(), error) {
+	if path == "" {
+		logger, err := logging.New(os.Stderr, level, format)
+		return logger, func() {}, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger, err := logging.New(file, level, format)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return logger, func() { file.Close() }, nil
+}
+
+// errFailed is returned by the commands when some files failed, which are
+// already logged
+var errFailed = errors.New("some files failed")
+
+// failure returns errFailed if failed
+func failure(failed bool) error {
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+// fatalError is an error that stops a command, logged with its message and
+// arguments once returned to main
+type fatalError struct {
+	msg  string
+	err  error
+	args []any
+}
+
+func (e *fatalError) Error() string {
+	return e.msg + ": " + e.err.Error()
+}
+
+func (e *fatalError) Unwrap() error {
+	return e.err
+}
+
+// fatal returns an error that stops the command
+func fatal(msg string, err error, args ...any) error {
+	return &fatalError{msg: msg, err: err, args: args}
+}
+
+// exitStatus logs the error of a command, if any, and returns the exit
+// status of the process
+func exitStatus(logger *slog.Logger, err error) int {
+	var fe *fatalError
+	if errors.As(err, &fe) {
+		logger.Error(fe.msg, append(fe.args, "error", fe.err)...)
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// readFileList reads the paths listed in a file, or in stdin if path is -
+func readFileList(path string) ([]string, error) {
+	if path == "-" {
+		return opts.ReadFileList(os.Stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
+	return opts.ReadFileList(file)
 }
@@ -0,0 +1,80 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"log/slog"
+
+	"github.com/lluissm/media-renamer/internal/output"
+	"github.com/lluissm/media-renamer/pkg/mediarenamer"
+)
+
+// recorder is the observer writing the results of a run to the output and
+// keeping them for the reports
+type recorder struct {
+	mediarenamer.BaseObserver
+	logger *slog.Logger
+	out    output.Writer
+	// keep keeps the results for the reports
+	keep    bool
+	results []mediarenamer.Result
+	// verify only records the files that are not named after their date,
+	// counting them as mismatches
+	verify     bool
+	mismatches int
+	// planning holds back the planned renames, to be confirmed before they
+	// are recorded
+	planning bool
+	plan     []mediarenamer.Result
+}
+
+func (r *recorder) FileRenamed(res mediarenamer.Result) {
+	if r.planning && res.Status == mediarenamer.StatusPlanned {
+		r.plan = append(r.plan, res)
+		return
+	}
+	r.record(res)
+}
+
+func (r *recorder) FileFailed(res mediarenamer.Result) {
+	r.record(res)
+}
+
+// record writes a result to the output
+func (r *recorder) record(res mediarenamer.Result) {
+	if r.verify {
+		if res.Status == mediarenamer.StatusAlreadyNamed {
+			return
+		}
+		if res.Status == mediarenamer.StatusPlanned {
+			r.mismatches++
+		}
+	}
+	if err := r.out.Result(res); err != nil {
+		r.logger.Error("Error writing output", "error", err)
+	}
+	if r.keep {
+		r.results = append(r.results, res)
+	}
+}
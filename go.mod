@@ -1,13 +1,18 @@
 module github.com/lluissm/media-renamer
 
-go 1.19
+go 1.21
 
 require (
 	github.com/barasher/go-exiftool v1.8.0
+	github.com/bmatcuk/doublestar/v4 v4.6.1
+	github.com/fsnotify/fsnotify v1.7.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
-require github.com/stretchr/objx v0.4.0 // indirect
+require (
+	github.com/stretchr/objx v0.4.0 // indirect
+	golang.org/x/sys v0.4.0 // indirect
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,232 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/lluissm/media-renamer/internal/process"
+)
+
+type (
+	// Cache is a process.Cache stored as json lines, one per file. Entries
+	// are appended as files are processed and the file is compacted on close.
+	Cache struct {
+		mu      sync.Mutex
+		path    string
+		file    *os.File
+		encoder *json.Encoder
+		records map[string]record
+		lines   int
+	}
+
+	// record is a line of the cache file: the cached entry together with
+	// what identifies the version of the file it belongs to
+	record struct {
+		Path        string `json:"path"`
+		Size        int64  `json:"size"`
+		ModTime     int64  `json:"mtime"`
+		Inode       uint64 `json:"inode,omitempty"`
+		Fingerprint string `json:"fingerprint"`
+		process.CacheEntry
+	}
+)
+
+// File is the name of the cache file inside the cache folder
+const File = "cache.jsonl"
+
+// DefaultPath returns $XDG_CACHE_HOME/media-renamer/cache.jsonl, falling
+// back to ~/.cache when XDG_CACHE_HOME is not set
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(dir, "media-renamer", File), nil
+}
+
+// Open loads the cache at path, creating it if it does not exist
+func Open(path string) (*Cache, error) {
+	records, lines, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{path: path, file: file, encoder: json.NewEncoder(file), records: records, lines: lines}, nil
+}
+
+// load reads the records of a cache file, later lines replacing earlier
+// ones for the same path. A missing file is an empty cache and unreadable
+// lines (e.g., from an interrupted run) are ignored.
+func load(path string) (map[string]record, int, error) {
+	records := map[string]record{}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return records, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines++
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil || r.Path == "" {
+			continue
+		}
+		records[r.Path] = r
+	}
+	return records, lines, scanner.Err()
+}
+
+func (c *Cache) Get(path string, info fs.FileInfo, fingerprint string) (process.CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.records[path]
+	if !ok || !r.matches(info) || r.Fingerprint != fingerprint {
+		return process.CacheEntry{}, false
+	}
+	return r.CacheEntry, true
+}
+
+func (c *Cache) Put(path string, info fs.FileInfo, fingerprint string, entry process.CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := newRecord(path, info, fingerprint, entry)
+	if old, ok := c.records[path]; ok && old.equal(r) {
+		return
+	}
+	c.records[path] = r
+	// A failed write only means the file will be extracted again next time
+	if c.encoder.Encode(r) == nil {
+		c.lines++
+	}
+}
+
+// Close closes the cache file, rewriting it without the replaced lines if
+// they are more than the current ones
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.file.Close(); err != nil {
+		return err
+	}
+	if c.lines > 2*len(c.records) {
+		return write(c.path, c.records)
+	}
+	return nil
+}
+
+// Prune removes from the cache at path the entries of files that no longer
+// exist or have changed, and returns how many were removed
+func Prune(path string) (int, error) {
+	records, _, err := load(path)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for p, r := range records {
+		if info, err := os.Stat(p); err != nil || !r.matches(info) {
+			delete(records, p)
+			removed++
+		}
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	return removed, write(path, records)
+}
+
+// write replaces the cache file at path with the given records
+func write(path string, records map[string]record) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), File+".*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, r := range records {
+		if err := encoder.Encode(r); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func newRecord(path string, info fs.FileInfo, fingerprint string, entry process.CacheEntry) record {
+	return record{
+		Path:        path,
+		Size:        info.Size(),
+		ModTime:     info.ModTime().UnixNano(),
+		Inode:       inode(info),
+		Fingerprint: fingerprint,
+		CacheEntry:  entry,
+	}
+}
+
+// equal returns true if both records are the same, comparing their times by value
+func (r record) equal(other record) bool {
+	a, b := r, other
+	a.Time, b.Time = nil, nil
+	if a != b || (r.Time == nil) != (other.Time == nil) {
+		return false
+	}
+	return r.Time == nil || r.Time.Equal(*other.Time)
+}
+
+// matches returns true if the record belongs to the current version of the file
+func (r record) matches(info fs.FileInfo) bool {
+	return r.Size == info.Size() && r.ModTime == info.ModTime().UnixNano() && r.Inode == inode(info)
+}
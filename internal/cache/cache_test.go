@@ -0,0 +1,161 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/process"
+	"github.com/stretchr/testify/assert"
+)
+
+var date = time.Date(2019, 8, 5, 14, 12, 13, 0, time.UTC)
+
+var entry = process.CacheEntry{DateField: "CreateDate", RawDate: "2019:08:05 14:12:13", Time: &date}
+
+func createFile(t *testing.T, path, content string) os.FileInfo {
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	return info
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/cache")
+	path, err := DefaultPath()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("/cache", "media-renamer", File), path)
+}
+
+func TestGetAndPut(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache", File)
+	file := filepath.Join(dir, "a.jpeg")
+	info := createFile(t, file, "a")
+
+	c, err := Open(cachePath)
+	assert.NoError(t, err)
+	_, ok := c.Get(file, info, "fp")
+	assert.False(t, ok)
+	c.Put(file, info, "fp", entry)
+	assert.NoError(t, c.Close())
+
+	// Entries are persisted
+	c, err = Open(cachePath)
+	assert.NoError(t, err)
+	defer c.Close()
+	cached, ok := c.Get(file, info, "fp")
+	assert.True(t, ok)
+	assert.Equal(t, "CreateDate", cached.DateField)
+	assert.True(t, date.Equal(*cached.Time))
+
+	// But not used with another configuration or once the file changes
+	_, ok = c.Get(file, info, "other")
+	assert.False(t, ok)
+	info = createFile(t, file, "changed")
+	_, ok = c.Get(file, info, "fp")
+	assert.False(t, ok)
+}
+
+func TestPutSameEntry(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, File)
+	file := filepath.Join(dir, "a.jpeg")
+	info := createFile(t, file, "a")
+
+	c, err := Open(cachePath)
+	assert.NoError(t, err)
+	c.Put(file, info, "fp", entry)
+	copied := date
+	c.Put(file, info, "fp", process.CacheEntry{DateField: "CreateDate", RawDate: "2019:08:05 14:12:13", Time: &copied})
+	assert.NoError(t, c.Close())
+
+	content, err := os.ReadFile(cachePath)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "\n"))
+}
+
+func TestCompaction(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, File)
+	file := filepath.Join(dir, "a.jpeg")
+	info := createFile(t, file, "a")
+
+	c, err := Open(cachePath)
+	assert.NoError(t, err)
+	for _, fp := range []string{"1", "2", "3"} {
+		c.Put(file, info, fp, entry)
+	}
+	assert.NoError(t, c.Close())
+
+	// Replaced lines are removed on close
+	content, err := os.ReadFile(cachePath)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "\n"))
+	assert.Contains(t, string(content), `"fingerprint":"3"`)
+}
+
+func TestInvalidLinesAreIgnored(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), File)
+	assert.NoError(t, os.WriteFile(cachePath, []byte("{\"path\":\"/a.jpeg\"}\n{\"path\":"), 0644))
+
+	c, err := Open(cachePath)
+	assert.NoError(t, err)
+	assert.Len(t, c.records, 1)
+	assert.NoError(t, c.Close())
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, File)
+
+	removed, err := Prune(cachePath)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	kept := filepath.Join(dir, "kept.jpeg")
+	deleted := filepath.Join(dir, "deleted.jpeg")
+	changed := filepath.Join(dir, "changed.jpeg")
+	c, err := Open(cachePath)
+	assert.NoError(t, err)
+	for _, file := range []string{kept, deleted, changed} {
+		c.Put(file, createFile(t, file, "a"), "fp", entry)
+	}
+	assert.NoError(t, c.Close())
+	assert.NoError(t, os.Remove(deleted))
+	createFile(t, changed, "changed")
+
+	removed, err = Prune(cachePath)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	records, _, err := load(cachePath)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Contains(t, records, kept)
+}
@@ -24,16 +24,44 @@ SOFTWARE.
 package config
 
 import (
+	_ "embed"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
+const (
+	// DefaultSource is the provenance of the file types embedded in the binary
+	DefaultSource = "defaults"
+	// UserConfigDir is the folder inside $XDG_CONFIG_HOME holding the user config
+	UserConfigDir = "media-renamer"
+	// UserConfigFile is the name of the user config file
+	UserConfigFile = "config.yml"
+	// ProjectConfigFile is the name of the config file looked up in the processed folder and its parents
+	ProjectConfigFile = ".media-renamer.yml"
+)
+
+//go:embed defaults.yml
+var defaultConfigFile []byte
+
 type (
 	Config struct {
 		fileTypes           []FileType
 		supportedExtensions []string
+		sources             map[string]string
+		include             []pattern
+		exclude             []pattern
+		hooks               Hooks
+		hookSources         map[string]string
+		shifts              []ShiftRule
+		layers              []string
 	}
 
 	DateField struct {
@@ -45,31 +73,292 @@ type (
 		Extension  string      `yaml:"extension"`
 		DateFields []DateField `yaml:"dateFields"`
 	}
+
+	// Hooks are shell commands run around the renames of a run
+	Hooks struct {
+		// PreRename runs before each rename, a non-zero exit status vetoes it
+		PreRename string `yaml:"preRename"`
+		// PostRename runs after each successful rename
+		PostRename string `yaml:"postRename"`
+		// PostRun runs once the files of a run are processed
+		PostRun string `yaml:"postRun"`
+	}
+
+	// Shift corrects the dates of the files matching all its set criteria,
+	// e.g., the ones of a camera whose clock was wrong
+	Shift struct {
+		// Make, Model and Serial match the camera, case insensitive
+		Make   string `yaml:"make,omitempty"`
+		Model  string `yaml:"model,omitempty"`
+		Serial string `yaml:"serial,omitempty"`
+		// Folder is a glob matching the end of the folder of the files,
+		// including its subfolders, e.g., "trip-2019" or "2019/*"
+		Folder string `yaml:"folder,omitempty"`
+		// From and To bound the dates of the files before the correction,
+		// as 2006-01-02 (the whole day for To) or 2006-01-02T15:04:05
+		From string `yaml:"from,omitempty"`
+		To   string `yaml:"to,omitempty"`
+		// Offset is the duration added to the dates, e.g., "1h" or "-30m"
+		Offset string `yaml:"offset"`
+	}
+
+	// ShiftRule is a validated Shift together with the layer it comes from
+	ShiftRule struct {
+		Shift
+		Source   string
+		offset   time.Duration
+		from, to time.Time
+	}
+
+	// Camera identifies the camera that took a file, from its metadata
+	Camera struct {
+		Make, Model, Serial string
+	}
+
+	// Layer is a configuration file together with a description of where it comes from
+	Layer struct {
+		Source string
+		Bytes  []byte
+		// Project is set for the config files found in the processed
+		// folders, which may come from anyone and cannot declare hooks
+		Project bool
+	}
+
+	// document is a configuration file. A file consisting only of a list
+	// of file types is also accepted.
+	document struct {
+		FileTypes []FileType `yaml:"fileTypes"`
+		Include   []string   `yaml:"include"`
+		Exclude   []string   `yaml:"exclude"`
+		Hooks     Hooks      `yaml:"hooks"`
+		Shifts    []Shift    `yaml:"shifts"`
+	}
+
+	// pattern is an include/exclude glob together with the layer it comes from
+	pattern struct {
+		glob   string
+		source string
+	}
 )
 
 // Load loads the configuration from the provided yaml file
 func LoadConfig(bytes []byte) (*Config, error) {
+	return Resolve(Layer{Source: DefaultSource, Bytes: bytes})
+}
+
+// Resolve merges the given layers in order. A file type of a later layer
+// replaces the one with the same extension of an earlier layer, new
+// extensions are appended.
+func Resolve(layers ...Layer) (*Config, error) {
+	cfg := &Config{
+		supportedExtensions: []string{},
+		sources:             map[string]string{},
+		hookSources:         map[string]string{},
+	}
+
+	for _, layer := range layers {
+		doc, err := parseDocument(layer.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling %s: %w", layer.Source, err)
+		}
+		cfg.layers = append(cfg.layers, layer.Source)
+		for _, f := range doc.FileTypes {
+			if err := validate(f); err != nil {
+				return nil, fmt.Errorf("invalid configuration in %s: %w", layer.Source, err)
+			}
+			cfg.merge(f, layer.Source)
+		}
+		for _, glob := range doc.Include {
+			cfg.include = append(cfg.include, pattern{glob, layer.Source})
+		}
+		for _, glob := range doc.Exclude {
+			cfg.exclude = append(cfg.exclude, pattern{glob, layer.Source})
+		}
+		if layer.Project && doc.Hooks != (Hooks{}) {
+			return nil, fmt.Errorf("invalid configuration in %s: hooks can only be declared in the user config file or the one given with -c", layer.Source)
+		}
+		cfg.mergeHooks(doc.Hooks, layer.Source)
+		for _, shift := range doc.Shifts {
+			rule, err := NewShiftRule(shift, layer.Source)
+			if err != nil {
+				return nil, fmt.Errorf("invalid configuration in %s: %w", layer.Source, err)
+			}
+			cfg.shifts = append(cfg.shifts, rule)
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseDocument unmarshals a configuration file, either a document or a
+// plain list of file types
+func parseDocument(bytes []byte) (*document, error) {
 	var fileTypes []FileType
-	err := yaml.Unmarshal(bytes, &fileTypes)
+	if err := yaml.Unmarshal(bytes, &fileTypes); err == nil {
+		return &document{FileTypes: fileTypes}, nil
+	}
+
+	var doc document
+	if err := yaml.UnmarshalStrict(bytes, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// validate checks that a file type has an extension and valid date fields
+func validate(fileType FileType) error {
+	if !strings.HasPrefix(fileType.Extension, ".") {
+		return fmt.Errorf("extension %q must start with a dot", fileType.Extension)
+	}
+	for _, dateField := range fileType.DateFields {
+		if dateField.Name == "" {
+			return fmt.Errorf("date field of %s without name", fileType.Extension)
+		}
+		if err := ValidateLayout(dateField.DateFormat); err != nil {
+			return fmt.Errorf("date field %s of %s: %w", dateField.Name, fileType.Extension, err)
+		}
+	}
+	return nil
+}
+
+// ValidateLayout returns an error if a dateFormat is not a go layout with
+// at least the year, month and day, e.g., "2006:01:02 15:04:05"
+func ValidateLayout(layout string) error {
+	reference := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	parsed, err := time.Parse(layout, reference.Format(layout))
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling the file: %w", err)
+		return fmt.Errorf("invalid date format %q: %w", layout, err)
+	}
+	if parsed.Year() != reference.Year() || parsed.Month() != reference.Month() || parsed.Day() != reference.Day() {
+		return fmt.Errorf("invalid date format %q: it must contain the year (2006), month (01) and day (02)", layout)
 	}
+	return nil
+}
 
-	supportedExtensions := []string{}
-	for _, f := range fileTypes {
-		supportedExtensions = append(supportedExtensions, f.Extension)
+// merge adds a file type to the configuration, replacing the one with the same extension if any
+func (c *Config) merge(fileType FileType, source string) {
+	c.sources[strings.ToLower(fileType.Extension)] = source
+	for i, f := range c.fileTypes {
+		if strings.EqualFold(f.Extension, fileType.Extension) {
+			c.fileTypes[i] = fileType
+			return
+		}
 	}
+	c.fileTypes = append(c.fileTypes, fileType)
+	c.supportedExtensions = append(c.supportedExtensions, fileType.Extension)
+}
 
-	return &Config{
-		fileTypes:           fileTypes,
-		supportedExtensions: supportedExtensions,
-	}, nil
+// mergeHooks replaces the hooks set in a layer, keeping the other ones
+func (c *Config) mergeHooks(hooks Hooks, source string) {
+	for _, hook := range []struct {
+		name    string
+		command string
+		target  *string
+	}{
+		{"preRename", hooks.PreRename, &c.hooks.PreRename},
+		{"postRename", hooks.PostRename, &c.hooks.PostRename},
+		{"postRun", hooks.PostRun, &c.hooks.PostRun},
+	} {
+		if hook.command != "" {
+			*hook.target = hook.command
+			c.hookSources[hook.name] = source
+		}
+	}
 }
 
-// FileConfig returns the configuration for a given extension, error if not found
+// Layers returns the configuration layers that apply to path, from lowest
+// to highest precedence: the embedded defaults, the user config file (if
+// user is set), the project config file found in path or its parents and
+// the custom config file provided in the command line (if any).
+func Layers(path, customPath string, user bool) ([]Layer, error) {
+	layers := []Layer{DefaultLayer()}
+
+	if userPath, err := UserConfigPath(); user && err == nil {
+		layer, err := readLayer(userPath)
+		if err == nil {
+			layers = append(layers, *layer)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	if projectPath, ok := FindProjectConfig(path); ok {
+		layer, err := readLayer(projectPath)
+		if err != nil {
+			return nil, err
+		}
+		layer.Project = true
+		layers = append(layers, *layer)
+	}
+
+	if customPath != "" {
+		layer, err := readLayer(customPath)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, *layer)
+	}
+
+	return layers, nil
+}
+
+// readLayer reads a configuration file from disk
+func readLayer(path string) (*Layer, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file %s: %w", path, err)
+	}
+	return &Layer{Source: path, Bytes: bytes}, nil
+}
+
+// DefaultLayer returns the configuration embedded in the binary
+func DefaultLayer() Layer {
+	return Layer{Source: DefaultSource, Bytes: defaultConfigFile}
+}
+
+// UserConfigPath returns $XDG_CONFIG_HOME/media-renamer/config.yml, falling
+// back to ~/.config when XDG_CONFIG_HOME is not set
+func UserConfigPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, UserConfigDir, UserConfigFile), nil
+}
+
+// FindProjectConfig looks for a project config file in path (or its folder
+// if path is a file) and its parents
+func FindProjectConfig(path string) (string, bool) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	for {
+		candidate := filepath.Join(dir, ProjectConfigFile)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// FileConfig returns the configuration for a given extension (case
+// insensitive), error if not found
 func (c *Config) FileConfig(ext string) (*FileType, error) {
 	for _, f := range c.fileTypes {
-		if f.Extension == ext {
+		if strings.EqualFold(f.Extension, ext) {
 			return &f, nil
 		}
 	}
@@ -77,12 +366,124 @@ func (c *Config) FileConfig(ext string) (*FileType, error) {
 }
 
 // FileIsSupported returns true if the file extension is present in the config
+// (case insensitive, so both IMG_0001.JPG and IMG_0001.jpg match ".jpg")
 func (c *Config) FileIsSupported(path string) bool {
 	fileExtension := filepath.Ext(path)
 	for _, ext := range c.supportedExtensions {
-		if fileExtension == ext {
+		if strings.EqualFold(fileExtension, ext) {
 			return true
 		}
 	}
 	return false
 }
+
+// Extensions returns the configured extensions in order
+func (c *Config) Extensions() []string {
+	return c.supportedExtensions
+}
+
+// Include returns the glob patterns a file must match to be processed, all
+// files are processed if empty
+func (c *Config) Include() []string {
+	return globs(c.include)
+}
+
+// Exclude returns the glob patterns of files and folders that are not processed
+func (c *Config) Exclude() []string {
+	return globs(c.exclude)
+}
+
+func globs(patterns []pattern) []string {
+	res := []string{}
+	for _, p := range patterns {
+		res = append(res, p.glob)
+	}
+	return res
+}
+
+// Hooks returns the configured hooks, empty commands are not set
+func (c *Config) Hooks() Hooks {
+	return c.hooks
+}
+
+// WithoutHooks returns a copy of the configuration with no hooks, for the
+// runs that must not execute commands
+func (c *Config) WithoutHooks() *Config {
+	cfg := *c
+	cfg.hooks = Hooks{}
+	return &cfg
+}
+
+// Shifts returns the shift rules, from lowest to highest precedence
+func (c *Config) Shifts() []ShiftRule {
+	return c.shifts
+}
+
+// WithShifts returns a copy of the configuration with rules added after its
+// shift rules, e.g., the ones computed at run time
+func (c *Config) WithShifts(rules ...ShiftRule) *Config {
+	cfg := *c
+	cfg.shifts = append(append([]ShiftRule{}, c.shifts...), rules...)
+	return &cfg
+}
+
+// Source returns the layer the configuration for a given extension comes from
+func (c *Config) Source(ext string) string {
+	return c.sources[strings.ToLower(ext)]
+}
+
+// WriteResolved writes the effective configuration as yaml, annotating
+// each file type and pattern with the layer it comes from
+func (c *Config) WriteResolved(w io.Writer) error {
+	fmt.Fprintln(w, "# Layers (lowest to highest precedence):")
+	for _, layer := range c.layers {
+		fmt.Fprintf(w, "#   - %s\n", layer)
+	}
+
+	fmt.Fprintln(w, "fileTypes:")
+	for _, f := range c.fileTypes {
+		out, err := yaml.Marshal([]FileType{f})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "# source: %s\n%s", c.Source(f.Extension), out)
+	}
+
+	for _, section := range []struct {
+		name     string
+		patterns []pattern
+	}{{"include", c.include}, {"exclude", c.exclude}} {
+		if len(section.patterns) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", section.name)
+		for _, p := range section.patterns {
+			fmt.Fprintf(w, "- %q # source: %s\n", p.glob, p.source)
+		}
+	}
+
+	if len(c.shifts) > 0 {
+		fmt.Fprintln(w, "shifts:")
+		for _, rule := range c.shifts {
+			out, err := yaml.Marshal([]Shift{rule.Shift})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "# source: %s\n%s", rule.Source, out)
+		}
+	}
+
+	if len(c.hookSources) > 0 {
+		fmt.Fprintln(w, "hooks:")
+		for _, hook := range []struct{ name, command string }{
+			{"preRename", c.hooks.PreRename},
+			{"postRename", c.hooks.PostRename},
+			{"postRun", c.hooks.PostRun},
+		} {
+			if hook.command != "" {
+				fmt.Fprintf(w, "  %s: %q # source: %s\n", hook.name, hook.command, c.hookSources[hook.name])
+			}
+		}
+	}
+	return nil
+}
@@ -24,8 +24,12 @@ SOFTWARE.
 package config
 
 import (
+	"bytes"
 	_ "embed"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -33,6 +37,12 @@ import (
 //go:embed testdata/config.yml
 var configFile []byte
 
+//go:embed testdata/override.yml
+var overrideFile []byte
+
+//go:embed testdata/document.yml
+var documentFile []byte
+
 func TestLoad_Success(t *testing.T) {
 	// Load valid yml file returns no error
 	_, err := LoadConfig(configFile)
@@ -45,6 +55,36 @@ func TestLoad_Error(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestLoad_InvalidFileType(t *testing.T) {
+	// Extensions must start with a dot and date formats must be valid layouts
+	_, err := LoadConfig([]byte("- extension: jpeg\n"))
+	assert.ErrorContains(t, err, "must start with a dot")
+	_, err = LoadConfig([]byte("- extension: .jpeg\n  dateFields:\n    - name: CreateDate\n      dateFormat: \"2022\"\n"))
+	assert.ErrorContains(t, err, "CreateDate")
+}
+
+func TestResolve_RejectsInvalidFileType(t *testing.T) {
+	// These configurations used to load and then fail every file of the
+	// type at run time, now the whole layer is rejected when loaded
+	for _, doc := range []string{
+		"- extension: jpeg\n  dateFields:\n    - name: CreateDate\n      dateFormat: \"2006:01:02 15:04:05\"\n",
+		"- extension: .jpeg\n  dateFields:\n    - name: CreateDate\n      dateFormat: \"YYYY:MM:DD hh:mm:ss\"\n",
+		"- extension: .jpeg\n  dateFields:\n    - dateFormat: \"2006:01:02 15:04:05\"\n",
+	} {
+		_, err := Resolve(DefaultLayer(), Layer{Source: "user.yml", Bytes: []byte(doc)})
+		assert.ErrorContains(t, err, "invalid configuration in user.yml", doc)
+	}
+}
+
+func TestValidateLayout(t *testing.T) {
+	for _, layout := range []string{"2006:01:02 15:04:05", "2006:01:02 15:04:05-07:00 DST", "2006-01-02T15:04:05Z07:00", "2006:01:02"} {
+		assert.NoError(t, ValidateLayout(layout), layout)
+	}
+	for _, layout := range []string{"", "2022", "15:04:05", "2006:01", "YYYY:MM:DD"} {
+		assert.Error(t, ValidateLayout(layout), layout)
+	}
+}
+
 func getTestConfig() *Config {
 	cfg, err := LoadConfig(configFile)
 	if err == nil {
@@ -72,10 +112,209 @@ func TestFileConfig_Error(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestFileConfig_CaseInsensitive(t *testing.T) {
+	cfg := getTestConfig()
+
+	fileConfig, err := cfg.FileConfig(".MOV")
+	assert.NoError(t, err)
+	assert.Equal(t, ".mov", fileConfig.Extension)
+}
+
 func TestFileIsSupported(t *testing.T) {
 	cfg := getTestConfig()
 
 	assert.True(t, cfg.FileIsSupported("file.mov"))
 	assert.True(t, cfg.FileIsSupported("file.jpeg"))
+	assert.True(t, cfg.FileIsSupported("file.JPEG"))
 	assert.False(t, cfg.FileIsSupported("file.docx"))
 }
+
+func TestResolve_MergesByExtension(t *testing.T) {
+	cfg, err := Resolve(
+		Layer{Source: "base", Bytes: configFile},
+		Layer{Source: "override", Bytes: overrideFile},
+	)
+	assert.NoError(t, err)
+
+	// Overridden file type is replaced as a whole
+	fileConfig, err := cfg.FileConfig(".jpeg")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(fileConfig.DateFields))
+	assert.Equal(t, "DateTimeOriginal", fileConfig.DateFields[0].Name)
+	assert.Equal(t, "override", cfg.Source(".jpeg"))
+
+	// Untouched file type is kept
+	assert.True(t, cfg.FileIsSupported("file.mov"))
+	assert.Equal(t, "base", cfg.Source(".mov"))
+
+	// New file type is added
+	assert.True(t, cfg.FileIsSupported("file.png"))
+	assert.Equal(t, "override", cfg.Source(".png"))
+}
+
+func TestResolve_Error(t *testing.T) {
+	_, err := Resolve(
+		Layer{Source: "base", Bytes: configFile},
+		Layer{Source: "bad", Bytes: []byte("bad_file")},
+	)
+	assert.ErrorContains(t, err, "bad")
+}
+
+func TestResolve_Document(t *testing.T) {
+	cfg, err := Resolve(
+		Layer{Source: "base", Bytes: configFile},
+		Layer{Source: "document", Bytes: documentFile},
+		Layer{Source: "extra", Bytes: []byte("exclude: [\"*.tmp\"]")},
+	)
+	assert.NoError(t, err)
+
+	assert.True(t, cfg.FileIsSupported("file.heic"))
+	assert.True(t, cfg.FileIsSupported("file.mov"))
+	assert.Equal(t, []string{"*.heic"}, cfg.Include())
+	assert.Equal(t, []string{"**/.thumbnails", "@eaDir", "*.tmp"}, cfg.Exclude())
+
+	var out bytes.Buffer
+	assert.NoError(t, cfg.WriteResolved(&out))
+	assert.Contains(t, out.String(), "exclude:\n- \"**/.thumbnails\" # source: document\n")
+	assert.Contains(t, out.String(), "- \"*.tmp\" # source: extra\n")
+}
+
+func TestResolve_UnknownKey(t *testing.T) {
+	_, err := Resolve(Layer{Source: "typo", Bytes: []byte("exclued: [\"*.tmp\"]")})
+	assert.Error(t, err)
+}
+
+func TestResolve_Hooks(t *testing.T) {
+	cfg, err := Resolve(
+		Layer{Source: "user", Bytes: []byte("hooks:\n  preRename: check.sh\n  postRun: notify.sh\n")},
+		Layer{Source: "project", Bytes: []byte("hooks:\n  postRun: thumbnails.sh\n")},
+	)
+	assert.NoError(t, err)
+	// Each hook comes from the last layer setting it
+	assert.Equal(t, Hooks{PreRename: "check.sh", PostRun: "thumbnails.sh"}, cfg.Hooks())
+
+	var out bytes.Buffer
+	assert.NoError(t, cfg.WriteResolved(&out))
+	assert.Contains(t, out.String(), "hooks:\n  preRename: \"check.sh\" # source: user\n  postRun: \"thumbnails.sh\" # source: project\n")
+
+	// The config files of the processed folders cannot run commands
+	_, err = Resolve(Layer{Source: "folder", Bytes: []byte("hooks:\n  postRun: rm.sh\n"), Project: true})
+	assert.Error(t, err)
+	_, err = Resolve(Layer{Source: "folder", Bytes: []byte("exclude: [\"*.tmp\"]\n"), Project: true})
+	assert.NoError(t, err)
+}
+
+func TestResolve_Shifts(t *testing.T) {
+	cfg, err := Resolve(
+		Layer{Source: "user", Bytes: []byte("shifts:\n  - make: Canon\n    offset: 1h\n")},
+		Layer{Source: "project", Bytes: []byte("shifts:\n  - folder: trip\n    from: 2019-03-31\n    to: 2019-10-27T03:00:00\n    offset: -30m\n")},
+	)
+	assert.NoError(t, err)
+	rules := cfg.Shifts()
+	assert.Len(t, rules, 2)
+	assert.Equal(t, time.Hour, rules[0].Offset())
+	assert.Equal(t, "project", rules[1].Source)
+
+	// Rules added at run time come last, without changing the configuration
+	rule, err := NewShiftRule(Shift{Serial: "1234", Offset: "-2m"}, "sync")
+	assert.NoError(t, err)
+	synced := cfg.WithShifts(rule)
+	assert.Len(t, synced.Shifts(), 3)
+	assert.Equal(t, "sync", synced.Shifts()[2].Source)
+	assert.Len(t, cfg.Shifts(), 2)
+
+	var out bytes.Buffer
+	assert.NoError(t, cfg.WriteResolved(&out))
+	assert.Contains(t, out.String(), "shifts:\n# source: user\n- make: Canon\n  offset: 1h\n")
+
+	for _, shift := range []string{"offset: 1 hour", "offset: 1h\n    from: 31/03/2019", "offset: 1h\n    folder: \"[trip\""} {
+		_, err = Resolve(Layer{Source: "invalid", Bytes: []byte("shifts:\n  - " + shift + "\n")})
+		assert.Error(t, err, shift)
+	}
+}
+
+func TestShiftRule_Matches(t *testing.T) {
+	date := time.Date(2019, 8, 5, 14, 12, 13, 0, time.UTC)
+	canon := Camera{Make: "Canon", Model: "Canon EOS 5D", Serial: "1234"}
+	dir := filepath.Join(string(filepath.Separator), "photos", "trip", "day1")
+
+	for _, test := range []struct {
+		shift Shift
+		match bool
+	}{
+		{Shift{}, true},
+		{Shift{Make: "canon", Model: "Canon EOS 5D"}, true},
+		{Shift{Make: "Canon", Serial: "4321"}, false},
+		{Shift{Folder: "trip"}, true},
+		{Shift{Folder: "photos/trip"}, true},
+		{Shift{Folder: "rip"}, false},
+		{Shift{From: "2019-08-05", To: "2019-08-05"}, true},
+		{Shift{From: "2019-08-05T15:00:00"}, false},
+		{Shift{To: "2019-08-04"}, false},
+	} {
+		test.shift.Offset = "1h"
+		rule, err := NewShiftRule(test.shift, "test")
+		assert.NoError(t, err)
+		assert.Equal(t, test.match, rule.Matches(canon, dir, date), test.shift)
+	}
+}
+
+func TestFindProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	assert.NoError(t, os.MkdirAll(nested, 0755))
+
+	_, ok := FindProjectConfig(nested)
+	assert.False(t, ok)
+
+	projectPath := filepath.Join(root, "a", ProjectConfigFile)
+	assert.NoError(t, os.WriteFile(projectPath, overrideFile, 0644))
+
+	path, ok := FindProjectConfig(nested)
+	assert.True(t, ok)
+	assert.Equal(t, projectPath, path)
+}
+
+func TestLayers(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	userPath := filepath.Join(xdg, UserConfigDir, UserConfigFile)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(userPath), 0755))
+	assert.NoError(t, os.WriteFile(userPath, configFile, 0644))
+
+	root := t.TempDir()
+	projectPath := filepath.Join(root, ProjectConfigFile)
+	assert.NoError(t, os.WriteFile(projectPath, overrideFile, 0644))
+
+	layers, err := Layers(root, "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(layers))
+	assert.Equal(t, DefaultSource, layers[0].Source)
+	assert.Equal(t, userPath, layers[1].Source)
+	assert.Equal(t, projectPath, layers[2].Source)
+	assert.True(t, layers[2].Project)
+	assert.False(t, layers[1].Project)
+
+	// The user config file is only loaded if asked
+	layers, err = Layers(root, "", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(layers))
+	assert.Equal(t, projectPath, layers[1].Source)
+
+	// Custom config file must exist
+	_, err = Layers(root, filepath.Join(root, "missing.yml"), true)
+	assert.Error(t, err)
+}
+
+func TestWriteResolved(t *testing.T) {
+	cfg, err := Resolve(
+		Layer{Source: "base", Bytes: configFile},
+		Layer{Source: "override", Bytes: overrideFile},
+	)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, cfg.WriteResolved(&out))
+	assert.Contains(t, out.String(), "# source: override\n- extension: .png")
+	assert.Contains(t, out.String(), "# source: base\n- extension: .mov")
+}
@@ -0,0 +1,115 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const (
+	// shiftDateLayout is the layout of the days bounding a shift rule
+	shiftDateLayout = "2006-01-02"
+	// shiftTimeLayout is the layout of the times bounding a shift rule
+	shiftTimeLayout = "2006-01-02T15:04:05"
+)
+
+// NewShiftRule validates a shift, e.g., one given in the command line
+func NewShiftRule(shift Shift, source string) (ShiftRule, error) {
+	rule := ShiftRule{Shift: shift, Source: source}
+	var err error
+	if rule.offset, err = time.ParseDuration(shift.Offset); err != nil {
+		return rule, fmt.Errorf("invalid shift offset %q: %w", shift.Offset, err)
+	}
+	if shift.Folder != "" && !doublestar.ValidatePattern(shift.Folder) {
+		return rule, fmt.Errorf("invalid shift folder %q", shift.Folder)
+	}
+	if rule.from, err = parseBound(shift.From, false); err != nil {
+		return rule, err
+	}
+	if rule.to, err = parseBound(shift.To, true); err != nil {
+		return rule, err
+	}
+	return rule, nil
+}
+
+// parseBound parses the start or end of the dates of a shift rule, a day
+// as end includes all of it
+func parseBound(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(shiftTimeLayout, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(shiftDateLayout, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid shift date %q, valid formats are %s and %s", value, shiftDateLayout, shiftTimeLayout)
+	}
+	if end {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
+
+// Offset returns the duration the rule adds to the dates
+func (r ShiftRule) Offset() time.Duration {
+	return r.offset
+}
+
+// Matches returns true if a file in dir taken by camera at date t matches
+// the criteria of the rule. Dates are compared by their wall clock.
+func (r ShiftRule) Matches(camera Camera, dir string, t time.Time) bool {
+	if !equalFold(r.Make, camera.Make) || !equalFold(r.Model, camera.Model) || !equalFold(r.Serial, camera.Serial) {
+		return false
+	}
+	if r.Folder != "" && !matchFolder(r.Folder, dir) {
+		return false
+	}
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if !r.from.IsZero() && t.Before(r.from) {
+		return false
+	}
+	return r.to.IsZero() || !t.After(r.to)
+}
+
+// equalFold returns true if a criterion is not set or matches value
+func equalFold(criterion, value string) bool {
+	return criterion == "" || strings.EqualFold(strings.TrimSpace(criterion), strings.TrimSpace(value))
+}
+
+// matchFolder returns true if the end of dir, or of one of its parents,
+// matches glob
+func matchFolder(glob, dir string) bool {
+	dir = filepath.ToSlash(dir)
+	if !strings.HasPrefix(glob, "/") && !strings.HasPrefix(glob, "**") {
+		glob = "**/" + glob
+	}
+	ok, _ := doublestar.Match(strings.TrimSuffix(glob, "/")+"/**", dir)
+	return ok
+}
@@ -0,0 +1,119 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package filesystem
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FS is the filesystem the media files are processed in
+type FS interface {
+	// WalkDir walks the tree rooted at root as filepath.WalkDir
+	WalkDir(root string, fn fs.WalkDirFunc) error
+	// Stat returns the info of a file, following symbolic links
+	Stat(path string) (fs.FileInfo, error)
+	// Lstat returns the info of a file, without following symbolic links
+	Lstat(path string) (fs.FileInfo, error)
+	// ReadFile returns the contents of a file
+	ReadFile(path string) ([]byte, error)
+	// Rename renames a file, replacing newpath if it exists
+	Rename(oldpath, newpath string) error
+	// Link creates newpath as a hard link to oldpath
+	Link(oldpath, newpath string) error
+	// Copy copies the contents and permissions of a file, replacing dst if
+	// it exists
+	Copy(src, dst string) error
+	// MkdirAll creates a folder and all its missing parents
+	MkdirAll(path string, perm fs.FileMode) error
+	// Remove removes a file or an empty folder
+	Remove(path string) error
+	// Chtimes changes the access and modification times of a file, a zero
+	// time leaves the corresponding time unchanged
+	Chtimes(path string, atime, mtime time.Time) error
+}
+
+// OS is the filesystem of the operating system
+type OS struct{}
+
+func (OS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(root, fn)
+}
+
+func (OS) Stat(path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (OS) Lstat(path string) (fs.FileInfo, error) {
+	return os.Lstat(path)
+}
+
+func (OS) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (OS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (OS) Link(oldpath, newpath string) error {
+	return os.Link(oldpath, newpath)
+}
+
+func (OS) Copy(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (OS) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (OS) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (OS) Chtimes(path string, atime, mtime time.Time) error {
+	return os.Chtimes(path, atime, mtime)
+}
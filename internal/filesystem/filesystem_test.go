@@ -0,0 +1,145 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package filesystem
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testFS checks the behaviour shared by all the implementations of FS in a
+// folder of fsys with the files a.jpeg, sub/b.jpeg and sub/c.jpeg
+func testFS(t *testing.T, fsys FS, root string) {
+	path := func(rel string) string {
+		return filepath.Join(root, filepath.FromSlash(rel))
+	}
+
+	// Walk in lexical order, skipping folders
+	walked := []string{}
+	err := fsys.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		assert.NoError(t, err)
+		rel, _ := filepath.Rel(root, p)
+		walked = append(walked, filepath.ToSlash(rel))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".", "a.jpeg", "sub", "sub/b.jpeg", "sub/c.jpeg"}, walked)
+
+	walked = []string{}
+	err = fsys.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		walked = append(walked, d.Name())
+		if d.IsDir() && d.Name() == "sub" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Base(root), "a.jpeg", "sub"}, walked)
+
+	info, err := fsys.Stat(path("a.jpeg"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a.jpeg", info.Name())
+	assert.Equal(t, int64(1), info.Size())
+	assert.False(t, info.IsDir())
+	_, err = fsys.Lstat(path("missing.jpeg"))
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	// Rename replaces the target file
+	assert.NoError(t, fsys.Rename(path("sub/b.jpeg"), path("sub/c.jpeg")))
+	data, err := fsys.ReadFile(path("sub/c.jpeg"))
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(data))
+	_, err = fsys.Stat(path("sub/b.jpeg"))
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	assert.Error(t, fsys.Rename(path("sub/c.jpeg"), path("missing/c.jpeg")))
+
+	// Link and copy
+	assert.NoError(t, fsys.Link(path("a.jpeg"), path("sub/link.jpeg")))
+	assert.Error(t, fsys.Link(path("a.jpeg"), path("sub/link.jpeg")))
+	assert.NoError(t, fsys.Copy(path("a.jpeg"), path("sub/copy.jpeg")))
+	data, err = fsys.ReadFile(path("sub/copy.jpeg"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+
+	// Times, a zero time is left unchanged
+	mtime := time.Date(2019, 8, 5, 14, 12, 13, 0, time.Local)
+	assert.NoError(t, fsys.Chtimes(path("a.jpeg"), time.Time{}, mtime))
+	info, err = fsys.Stat(path("a.jpeg"))
+	assert.NoError(t, err)
+	assert.True(t, mtime.Equal(info.ModTime()))
+	assert.NoError(t, fsys.Chtimes(path("a.jpeg"), mtime, time.Time{}))
+	info, err = fsys.Stat(path("a.jpeg"))
+	assert.NoError(t, err)
+	assert.True(t, mtime.Equal(info.ModTime()))
+	assert.Error(t, fsys.Chtimes(path("missing.jpeg"), mtime, mtime))
+
+	// Folders
+	assert.NoError(t, fsys.MkdirAll(path("x/y"), 0755))
+	info, err = fsys.Stat(path("x/y"))
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.Error(t, fsys.MkdirAll(path("a.jpeg/y"), 0755))
+	assert.NoError(t, fsys.Rename(path("x"), path("z")))
+	_, err = fsys.Stat(path("z/y"))
+	assert.NoError(t, err)
+
+	// Only files and empty folders can be removed
+	assert.Error(t, fsys.Remove(path("z")))
+	assert.NoError(t, fsys.Remove(path("z/y")))
+	assert.NoError(t, fsys.Remove(path("z")))
+	assert.NoError(t, fsys.Remove(path("sub/link.jpeg")))
+	_, err = fsys.Stat(path("a.jpeg"))
+	assert.NoError(t, err)
+}
+
+func TestOS(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "sub"), 0755))
+	for name, data := range map[string]string{"a.jpeg": "a", "sub/b.jpeg": "b", "sub/c.jpeg": "c"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(data), 0644))
+	}
+	testFS(t, OS{}, root)
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	root := filepath.Join(string(filepath.Separator), "photos")
+	for name, data := range map[string]string{"a.jpeg": "a", "sub/b.jpeg": "b", "sub/c.jpeg": "c"} {
+		assert.NoError(t, m.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(data), 0644))
+	}
+	testFS(t, m, root)
+
+	// Hard links share their contents
+	assert.NoError(t, m.Link(filepath.Join(root, "a.jpeg"), filepath.Join(root, "b.jpeg")))
+	assert.NoError(t, m.WriteFile(filepath.Join(root, "a.jpeg"), []byte("new"), 0644))
+	data, err := m.ReadFile(filepath.Join(root, "b.jpeg"))
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+}
@@ -0,0 +1,309 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package filesystem
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Memory is an in-memory filesystem, to test the processing of files
+	// without touching the disk. Paths are cleaned but not made absolute.
+	// Access times are not kept.
+	Memory struct {
+		mu    sync.Mutex
+		files map[string]*memoryFile
+	}
+
+	// memoryFile is a file or folder of a Memory filesystem. Hard links
+	// share the same memoryFile.
+	memoryFile struct {
+		data    []byte
+		mode    fs.FileMode
+		modTime time.Time
+	}
+
+	// memoryInfo is the fs.FileInfo of a memoryFile
+	memoryInfo struct {
+		name string
+		file memoryFile
+	}
+)
+
+// NewMemory returns an empty in-memory filesystem
+func NewMemory() *Memory {
+	return &Memory{files: map[string]*memoryFile{}}
+}
+
+// WriteFile creates or truncates a file with the given contents, creating
+// its missing parent folders
+func (m *Memory) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path = filepath.Clean(path)
+	if err := m.mkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// As os.WriteFile, an existing file is truncated, not replaced, so
+	// that its hard links see the new contents
+	if f, ok := m.files[path]; ok {
+		if f.mode.IsDir() {
+			return &fs.PathError{Op: "write", Path: path, Err: fs.ErrExist}
+		}
+		f.data = append([]byte{}, data...)
+		f.modTime = time.Now()
+		return nil
+	}
+	m.files[path] = &memoryFile{data: append([]byte{}, data...), mode: perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (m *Memory) WalkDir(root string, fn fs.WalkDirFunc) error {
+	root = filepath.Clean(root)
+	info, err := m.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = m.walkDir(root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkDir walks a folder in lexical order, as filepath.WalkDir
+func (m *Memory) walkDir(path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+	for _, child := range m.children(path) {
+		info, err := m.Lstat(child)
+		if err != nil {
+			// Removed while walking the folder
+			continue
+		}
+		if err := m.walkDir(child, fs.FileInfoToDirEntry(info), fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// children returns the paths of the files and folders directly in a folder, sorted
+func (m *Memory) children(dir string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := []string{}
+	for path := range m.files {
+		if path != dir && filepath.Dir(path) == dir {
+			res = append(res, path)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (m *Memory) Stat(path string) (fs.FileInfo, error) {
+	return m.Lstat(path)
+}
+
+func (m *Memory) Lstat(path string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path = filepath.Clean(path)
+	f, ok := m.files[path]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: path, Err: fs.ErrNotExist}
+	}
+	return &memoryInfo{name: filepath.Base(path), file: *f}, nil
+}
+
+func (m *Memory) ReadFile(path string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path = filepath.Clean(path)
+	f, ok := m.files[path]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+	if f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: path, Err: fs.ErrInvalid}
+	}
+	return append([]byte{}, f.data...), nil
+}
+
+func (m *Memory) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	f, ok := m.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if err := m.checkParent(newpath); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	if target, ok := m.files[newpath]; ok && target.mode.IsDir() && oldpath != newpath {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrExist}
+	}
+	delete(m.files, oldpath)
+	m.files[newpath] = f
+	// The contents of a folder move with it
+	if f.mode.IsDir() {
+		prefix := oldpath + string(filepath.Separator)
+		for path, child := range m.files {
+			if strings.HasPrefix(path, prefix) {
+				delete(m.files, path)
+				m.files[filepath.Join(newpath, strings.TrimPrefix(path, prefix))] = child
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Link(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	f, ok := m.files[oldpath]
+	if !ok || f.mode.IsDir() {
+		return &os.LinkError{Op: "link", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if _, ok := m.files[newpath]; ok {
+		return &os.LinkError{Op: "link", Old: oldpath, New: newpath, Err: fs.ErrExist}
+	}
+	if err := m.checkParent(newpath); err != nil {
+		return &os.LinkError{Op: "link", Old: oldpath, New: newpath, Err: err}
+	}
+	m.files[newpath] = f
+	return nil
+}
+
+func (m *Memory) Copy(src, dst string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	src, dst = filepath.Clean(src), filepath.Clean(dst)
+	f, ok := m.files[src]
+	if !ok || f.mode.IsDir() {
+		return &fs.PathError{Op: "copy", Path: src, Err: fs.ErrNotExist}
+	}
+	if target, ok := m.files[dst]; ok && target.mode.IsDir() {
+		return &fs.PathError{Op: "copy", Path: dst, Err: fs.ErrExist}
+	}
+	if err := m.checkParent(dst); err != nil {
+		return &fs.PathError{Op: "copy", Path: dst, Err: err}
+	}
+	m.files[dst] = &memoryFile{data: append([]byte{}, f.data...), mode: f.mode, modTime: time.Now()}
+	return nil
+}
+
+func (m *Memory) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mkdirAll(filepath.Clean(path), perm)
+}
+
+// mkdirAll creates a folder and its parents, the lock must be held
+func (m *Memory) mkdirAll(path string, perm fs.FileMode) error {
+	if f, ok := m.files[path]; ok {
+		if !f.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrExist}
+		}
+		return nil
+	}
+	if parent := filepath.Dir(path); parent != path {
+		if err := m.mkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	m.files[path] = &memoryFile{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (m *Memory) Chtimes(path string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path = filepath.Clean(path)
+	f, ok := m.files[path]
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: path, Err: fs.ErrNotExist}
+	}
+	if !mtime.IsZero() {
+		f.modTime = mtime
+	}
+	return nil
+}
+
+func (m *Memory) Remove(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path = filepath.Clean(path)
+	f, ok := m.files[path]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: path, Err: fs.ErrNotExist}
+	}
+	if f.mode.IsDir() {
+		prefix := path + string(filepath.Separator)
+		for other := range m.files {
+			if strings.HasPrefix(other, prefix) {
+				return &fs.PathError{Op: "remove", Path: path, Err: fs.ErrExist}
+			}
+		}
+	}
+	delete(m.files, path)
+	return nil
+}
+
+// checkParent returns an error if the folder of path does not exist, the
+// lock must be held
+func (m *Memory) checkParent(path string) error {
+	parent := filepath.Dir(path)
+	if parent == path {
+		return nil
+	}
+	if f, ok := m.files[parent]; !ok || !f.mode.IsDir() {
+		return fs.ErrNotExist
+	}
+	return nil
+}
+
+func (i *memoryInfo) Name() string       { return i.name }
+func (i *memoryInfo) Size() int64        { return int64(len(i.file.data)) }
+func (i *memoryInfo) Mode() fs.FileMode  { return i.file.mode }
+func (i *memoryInfo) ModTime() time.Time { return i.file.modTime }
+func (i *memoryInfo) IsDir() bool        { return i.file.mode.IsDir() }
+func (i *memoryInfo) Sys() any           { return nil }
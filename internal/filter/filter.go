@@ -0,0 +1,78 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package filter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Filter decides which paths are processed according to include and
+// exclude glob patterns (doublestar syntax, ** matches any number of
+// folders). Paths are relative to the processed folder and use forward
+// slashes. Patterns without a slash are matched against the base name, so
+// "@eaDir" is the same as "**/@eaDir".
+type Filter struct {
+	include []string
+	exclude []string
+}
+
+// New returns a Filter, error if any of the patterns is not valid
+func New(include, exclude []string) (*Filter, error) {
+	for _, p := range append(append([]string{}, include...), exclude...) {
+		if !doublestar.ValidatePattern(p) {
+			return nil, fmt.Errorf("invalid glob pattern %q", p)
+		}
+	}
+	return &Filter{include: include, exclude: exclude}, nil
+}
+
+// Excluded returns true if the path matches any of the exclude patterns. A
+// folder that is excluded is not walked at all.
+func (f *Filter) Excluded(rel string) bool {
+	return matchAny(f.exclude, rel)
+}
+
+// Included returns true if there are no include patterns or the path matches
+// any of them. It only applies to files.
+func (f *Filter) Included(rel string) bool {
+	return len(f.include) == 0 || matchAny(f.include, rel)
+}
+
+// matchAny returns true if rel matches any of the patterns
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		name := rel
+		if !strings.Contains(p, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := doublestar.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
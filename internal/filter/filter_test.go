@@ -0,0 +1,73 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_Error(t *testing.T) {
+	_, err := New(nil, []string{"[unclosed"})
+	assert.Error(t, err)
+
+	_, err = New([]string{"[unclosed"}, nil)
+	assert.Error(t, err)
+}
+
+func TestExcluded(t *testing.T) {
+	f, err := New(nil, []string{"**/.thumbnails", "@eaDir", "Lightroom Catalog/**", "*.tmp.jpg"})
+	assert.NoError(t, err)
+
+	// Double star patterns match at any depth
+	assert.True(t, f.Excluded(".thumbnails"))
+	assert.True(t, f.Excluded("2021/summer/.thumbnails"))
+
+	// Patterns without a slash match the base name
+	assert.True(t, f.Excluded("@eaDir"))
+	assert.True(t, f.Excluded("nas/photos/@eaDir"))
+	assert.True(t, f.Excluded("2021/IMG_0001.tmp.jpg"))
+
+	// Trailing double star matches the folder itself and its content
+	assert.True(t, f.Excluded("Lightroom Catalog"))
+	assert.True(t, f.Excluded("Lightroom Catalog/Previews/a.jpg"))
+	assert.False(t, f.Excluded("2021/Lightroom Catalog"))
+
+	assert.False(t, f.Excluded("2021/IMG_0001.jpg"))
+}
+
+func TestIncluded(t *testing.T) {
+	f, err := New(nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, f.Included("any/file.jpg"))
+
+	f, err = New([]string{"*.heic", "2021/**/*.mov"}, nil)
+	assert.NoError(t, err)
+	assert.True(t, f.Included("IMG_0001.heic"))
+	assert.True(t, f.Included("2020/IMG_0001.heic"))
+	assert.True(t, f.Included("2021/summer/IMG_0002.mov"))
+	assert.False(t, f.Included("2020/IMG_0002.mov"))
+	assert.False(t, f.Included("IMG_0003.jpg"))
+}
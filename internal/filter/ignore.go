@@ -0,0 +1,201 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package filter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// IgnoreFileName is the name of the file with gitignore-style patterns that
+// applies to the folder containing it and all its subfolders
+const IgnoreFileName = ".media-renamer-ignore"
+
+type (
+	// Ignore holds the rules of the ignore files loaded for each folder
+	Ignore struct {
+		rules    map[string][]ignoreRule
+		readFile func(path string) ([]byte, error)
+	}
+
+	// ignoreRule is a line of an ignore file
+	ignoreRule struct {
+		pattern string
+		negate  bool
+		dirOnly bool
+	}
+)
+
+// NewIgnore returns an Ignore without rules, reading the ignore files from disk
+func NewIgnore() *Ignore {
+	return NewIgnoreReader(os.ReadFile)
+}
+
+// NewIgnoreReader returns an Ignore without rules, reading the ignore files
+// with readFile
+func NewIgnoreReader(readFile func(path string) ([]byte, error)) *Ignore {
+	return &Ignore{rules: map[string][]ignoreRule{}, readFile: readFile}
+}
+
+// Load reads the ignore file of a folder, if present
+func (i *Ignore) Load(dir string) error {
+	path := filepath.Join(dir, IgnoreFileName)
+	data, err := i.readFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rules, err := parseIgnore(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", path, err)
+	}
+	if len(rules) > 0 {
+		// Ignored looks the rules up by absolute folder
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		i.rules[abs] = rules
+	}
+	return nil
+}
+
+// LoadParents reads the ignore files of a folder and all its parents, so the
+// rules of a parent folder also apply when processing one of its subfolders
+func (i *Ignore) LoadParents(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for {
+		if err := i.Load(dir); err != nil {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// Ignored returns true if the path is ignored by the rules of the folders
+// containing it. As in gitignore, the last matching rule wins and the rules
+// of a deeper folder take precedence over the ones of its parents.
+func (i *Ignore) Ignored(path string, isDir bool) bool {
+	if len(i.rules) == 0 {
+		return false
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	// Folders containing path, from the outermost to the innermost
+	dirs := []string{}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	ignored := false
+	for _, dir := range dirs {
+		rules, ok := i.rules[dir]
+		if !ok {
+			continue
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, r := range rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if ok, _ := doublestar.Match(r.pattern, rel); ok {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// parseIgnore parses the content of an ignore file:
+//   - blank lines and lines starting with # are ignored
+//   - a leading ! negates the pattern, re-including what a previous one ignored
+//   - a trailing / only matches folders
+//   - a pattern with a leading or middle / is relative to the ignore file
+//     folder, otherwise it matches at any depth
+func parseIgnore(r io.Reader) ([]ignoreRule, error) {
+	rules := []ignoreRule{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		if strings.Contains(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else {
+			line = "**/" + line
+		}
+		if !doublestar.ValidatePattern(line) {
+			return nil, fmt.Errorf("invalid pattern %q", scanner.Text())
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
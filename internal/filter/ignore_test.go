@@ -0,0 +1,120 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIgnore(t *testing.T) {
+	content := `
+# comment
+*.tmp
+!keep.tmp
+/curated
+raw/
+2021/**/edits
+\#hash
+`
+	rules, err := parseIgnore(strings.NewReader(content))
+	assert.NoError(t, err)
+	assert.Equal(t, []ignoreRule{
+		{pattern: "**/*.tmp"},
+		{pattern: "**/keep.tmp", negate: true},
+		{pattern: "curated"},
+		{pattern: "**/raw", dirOnly: true},
+		{pattern: "2021/**/edits"},
+		{pattern: "**/#hash"},
+	}, rules)
+
+	_, err = parseIgnore(strings.NewReader("[unclosed"))
+	assert.Error(t, err)
+}
+
+func writeIgnore(t *testing.T, dir, content string) {
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, IgnoreFileName), []byte(content), 0644))
+}
+
+func TestIgnored(t *testing.T) {
+	root := t.TempDir()
+	writeIgnore(t, root, "*.tmp\n/curated\nraw/\n")
+	writeIgnore(t, filepath.Join(root, "2021"), "!keep.tmp\n")
+
+	ignore := NewIgnore()
+	assert.NoError(t, ignore.Load(root))
+	assert.NoError(t, ignore.Load(filepath.Join(root, "2021")))
+	assert.NoError(t, ignore.Load(filepath.Join(root, "missing")))
+
+	// Unanchored patterns match at any depth
+	assert.True(t, ignore.Ignored(filepath.Join(root, "a.tmp"), false))
+	assert.True(t, ignore.Ignored(filepath.Join(root, "2020", "a.tmp"), false))
+
+	// Deeper ignore files can re-include files
+	assert.True(t, ignore.Ignored(filepath.Join(root, "keep.tmp"), false))
+	assert.False(t, ignore.Ignored(filepath.Join(root, "2021", "keep.tmp"), false))
+	assert.True(t, ignore.Ignored(filepath.Join(root, "2021", "other.tmp"), false))
+
+	// Anchored patterns are relative to the ignore file folder
+	assert.True(t, ignore.Ignored(filepath.Join(root, "curated"), true))
+	assert.False(t, ignore.Ignored(filepath.Join(root, "2021", "curated"), true))
+
+	// Directory only patterns
+	assert.True(t, ignore.Ignored(filepath.Join(root, "2021", "raw"), true))
+	assert.False(t, ignore.Ignored(filepath.Join(root, "2021", "raw"), false))
+
+	assert.False(t, ignore.Ignored(filepath.Join(root, "a.jpeg"), false))
+}
+
+func TestLoadParents(t *testing.T) {
+	root := t.TempDir()
+	writeIgnore(t, root, "curated/\n")
+	nested := filepath.Join(root, "photos", "curated")
+	assert.NoError(t, os.MkdirAll(nested, 0755))
+
+	ignore := NewIgnore()
+	assert.NoError(t, ignore.LoadParents(filepath.Join(root, "photos")))
+	assert.True(t, ignore.Ignored(nested, true))
+}
+
+func TestIgnored_RelativeDir(t *testing.T) {
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	root := t.TempDir()
+	assert.NoError(t, os.Chdir(root))
+	t.Cleanup(func() { assert.NoError(t, os.Chdir(wd)) })
+	writeIgnore(t, "photos", "*.tmp\n")
+
+	// The rules of a folder given as a relative path apply to its files
+	ignore := NewIgnore()
+	assert.NoError(t, ignore.Load("photos"))
+	assert.True(t, ignore.Ignored(filepath.Join("photos", "a.tmp"), false))
+	assert.True(t, ignore.Ignored(filepath.Join(root, "photos", "a.tmp"), false))
+	assert.False(t, ignore.Ignored(filepath.Join("photos", "a.jpeg"), false))
+}
@@ -0,0 +1,71 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Names of the hooks, passed to the commands in the MEDIA_RENAMER_HOOK
+// variable and the "hook" field of their input
+const (
+	PreRename  = "preRename"
+	PostRename = "postRename"
+	PostRun    = "postRun"
+)
+
+// VarPrefix is the prefix of the environment variables passed to the hooks
+const VarPrefix = "MEDIA_RENAMER_"
+
+// Stderr receives the output of the hooks, so that it does not mix with the
+// machine readable output of the run in stdout
+var Stderr io.Writer = os.Stderr
+
+// Run runs a hook command with the system shell. The name of the hook and
+// vars are added to its environment, prefixed with VarPrefix, and payload
+// is written as JSON to its stdin. It returns an error if the command
+// cannot be run or exits with a non-zero status.
+func Run(name, command string, vars map[string]string, payload interface{}) error {
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	cmd := shell(command)
+	cmd.Env = append(os.Environ(), VarPrefix+"HOOK="+name)
+	for key, value := range vars {
+		cmd.Env = append(cmd.Env, VarPrefix+key+"="+value)
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = Stderr
+	cmd.Stderr = Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook %q: %w", name, command, err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hooks
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook commands are written for sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	var stderr bytes.Buffer
+	Stderr = &stderr
+	defer func() { Stderr = os.Stderr }()
+
+	// The variables and the payload are passed to the command
+	command := `echo "$MEDIA_RENAMER_HOOK $MEDIA_RENAMER_OLD_PATH" > ` + out + ` && cat >> ` + out + ` && echo done`
+	err := Run(PreRename, command, map[string]string{"OLD_PATH": "a.jpeg"}, map[string]string{"path": "a.jpeg"})
+	assert.NoError(t, err)
+	written, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "preRename a.jpeg\n{\"path\":\"a.jpeg\"}", string(written))
+	assert.Equal(t, "done\n", stderr.String())
+
+	// A non-zero exit status is an error
+	err = Run(PreRename, "exit 3", nil, nil)
+	assert.ErrorContains(t, err, "exit status 3")
+}
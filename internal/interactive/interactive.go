@@ -0,0 +1,129 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package interactive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/lluissm/media-renamer/internal/process"
+)
+
+// Confirm shows each planned rename and asks the user whether to accept it,
+// skip it, edit the new name, accept all the remaining ones with the same
+// extension or quit. It returns the accepted renames (with the edited names)
+// and the ones that were not accepted, with StatusSkipped.
+func Confirm(in io.Reader, out io.Writer, plan []process.Result) (accepted, skipped []process.Result, err error) {
+	reader := bufio.NewReader(in)
+	acceptAll := map[string]bool{}
+	accepted = []process.Result{}
+	skipped = []process.Result{}
+
+	for i, res := range plan {
+		ext := strings.ToLower(filepath.Ext(res.Path))
+		if acceptAll[ext] {
+			accepted = append(accepted, res)
+			continue
+		}
+
+		fmt.Fprintf(out, "[%d/%d] %s -> %s\n", i+1, len(plan), res.Path, filepath.Base(res.NewPath))
+		for decided := false; !decided; {
+			fmt.Fprintf(out, "Rename? [y]es, [n]o, [e]dit name, [a]ll %s files, [q]uit: ", ext)
+			answer, err := readLine(reader)
+			if err == io.EOF {
+				return accepted, append(skipped, skip(plan[i:])...), nil
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+
+			switch strings.ToLower(answer) {
+			case "y", "yes":
+				accepted = append(accepted, res)
+				decided = true
+			case "n", "no":
+				skipped = append(skipped, skip(plan[i:i+1])...)
+				decided = true
+			case "a", "all":
+				acceptAll[ext] = true
+				accepted = append(accepted, res)
+				decided = true
+			case "q", "quit":
+				return accepted, append(skipped, skip(plan[i:])...), nil
+			case "e", "edit":
+				edited, ok, err := editName(reader, out, res)
+				if err != nil && err != io.EOF {
+					return nil, nil, err
+				}
+				if ok {
+					accepted = append(accepted, edited)
+					decided = true
+				}
+			default:
+				fmt.Fprintln(out, "Please answer y, n, e, a or q")
+			}
+		}
+	}
+	return accepted, skipped, nil
+}
+
+// editName asks for a new file name, in the same folder as the original file
+func editName(reader *bufio.Reader, out io.Writer, res process.Result) (process.Result, bool, error) {
+	fmt.Fprintf(out, "New name [%s]: ", filepath.Base(res.NewPath))
+	name, err := readLine(reader)
+	if err != nil {
+		return res, false, err
+	}
+	if name == "" {
+		return res, true, nil
+	}
+	if strings.ContainsAny(name, `/\`) {
+		fmt.Fprintln(out, "The name cannot contain path separators")
+		return res, false, nil
+	}
+	res.NewPath = filepath.Join(filepath.Dir(res.NewPath), name)
+	return res, true, nil
+}
+
+// readLine returns the next line of input without surrounding spaces
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimSpace(line), err
+}
+
+// skip marks planned renames as skipped by the user
+func skip(plan []process.Result) []process.Result {
+	res := []process.Result{}
+	for _, r := range plan {
+		r.Status = process.StatusSkipped
+		res = append(res, r)
+	}
+	return res
+}
@@ -0,0 +1,96 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package interactive
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lluissm/media-renamer/internal/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func planned(path, newName string) process.Result {
+	return process.Result{
+		Path:    path,
+		NewPath: filepath.Join(filepath.Dir(path), newName),
+		Status:  process.StatusPlanned,
+	}
+}
+
+var plan = []process.Result{
+	planned("a.jpeg", "2019_01_01_00_00_00.jpeg"),
+	planned("b.jpeg", "2019_01_01_00_00_01.jpeg"),
+	planned("c.mov", "2019_01_01_00_00_02.mov"),
+	planned("d.JPEG", "2019_01_01_00_00_03.JPEG"),
+	planned("e.mov", "2019_01_01_00_00_04.mov"),
+}
+
+func paths(results []process.Result) []string {
+	res := []string{}
+	for _, r := range results {
+		res = append(res, r.Path)
+	}
+	return res
+}
+
+func TestConfirm(t *testing.T) {
+	var out bytes.Buffer
+	// yes, no, unknown then all .mov, all .jpeg
+	in := strings.NewReader("y\nn\nwhat\na\na\n")
+	accepted, skipped, err := Confirm(in, &out, plan)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"a.jpeg", "c.mov", "d.JPEG", "e.mov"}, paths(accepted))
+	assert.Equal(t, []string{"b.jpeg"}, paths(skipped))
+	assert.Equal(t, process.StatusSkipped, skipped[0].Status)
+	assert.Contains(t, out.String(), "[1/5] a.jpeg -> 2019_01_01_00_00_00.jpeg")
+	assert.Contains(t, out.String(), "Please answer")
+	// e.mov is accepted without asking
+	assert.NotContains(t, out.String(), "e.mov")
+}
+
+func TestConfirm_Edit(t *testing.T) {
+	var out bytes.Buffer
+	in := strings.NewReader("e\nsub/name.jpeg\ne\nbirthday.jpeg\ne\n\nq\n")
+	accepted, skipped, err := Confirm(in, &out, plan)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, len(accepted))
+	assert.Equal(t, "birthday.jpeg", accepted[0].NewPath)
+	assert.Equal(t, "2019_01_01_00_00_01.jpeg", accepted[1].NewPath)
+	assert.Contains(t, out.String(), "cannot contain path separators")
+
+	// Quitting skips the remaining ones
+	assert.Equal(t, []string{"c.mov", "d.JPEG", "e.mov"}, paths(skipped))
+}
+
+func TestConfirm_EndOfInput(t *testing.T) {
+	accepted, skipped, err := Confirm(strings.NewReader("y"), &bytes.Buffer{}, plan)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(accepted))
+	assert.Equal(t, 4, len(skipped))
+}
@@ -0,0 +1,264 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package journal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/filesystem"
+	"github.com/lluissm/media-renamer/internal/logging"
+	"github.com/lluissm/media-renamer/internal/process"
+)
+
+const (
+	// extension of the journal files
+	extension = ".jsonl"
+	// undoneSuffix is appended to the name of a journal once it is undone
+	undoneSuffix = ".undone"
+)
+
+// ErrNoJournal is returned when there is no journal to undo
+var ErrNoJournal = errors.New("no journal found")
+
+type (
+	// Entry is a rename recorded in a journal
+	Entry struct {
+		From string    `json:"from"`
+		To   string    `json:"to"`
+		Time time.Time `json:"time"`
+	}
+
+	// Journal records the renames of a run, one json entry per line, so
+	// they can be undone
+	Journal struct {
+		mu      sync.Mutex
+		file    *os.File
+		encoder *json.Encoder
+		entries int
+	}
+
+	// Renamer is a filesystem that records its successful renames in a journal
+	Renamer struct {
+		filesystem.FS
+		Journal *Journal
+	}
+)
+
+// Dir returns the folder where journals are stored by default:
+// $XDG_STATE_HOME/media-renamer/journal, falling back to ~/.local/state
+func Dir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "media-renamer", "journal"), nil
+}
+
+// NewPath returns the path of a new journal in dir, named after the current time
+func NewPath(dir string) string {
+	return filepath.Join(dir, time.Now().Format("20060102T150405.000000000")+extension)
+}
+
+// Create creates a journal at path, and its folder if needed
+func Create(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// Record appends a rename to the journal
+func (j *Journal) Record(from, to string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries++
+	return j.encoder.Encode(Entry{From: from, To: to, Time: time.Now()})
+}
+
+// Close closes the journal, removing it if nothing was recorded
+func (j *Journal) Close() error {
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+	if j.entries == 0 {
+		if info, err := os.Stat(j.file.Name()); err == nil && info.Size() == 0 {
+			return os.Remove(j.file.Name())
+		}
+	}
+	return nil
+}
+
+// Path returns the path of the journal file
+func (j *Journal) Path() string {
+	return j.file.Name()
+}
+
+func (r *Renamer) Rename(oldpath string, newpath string) error {
+	if err := r.FS.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	return r.Journal.Record(oldpath, newpath)
+}
+
+// Latest returns the most recent journal in dir that has not been undone yet
+func Latest(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ErrNoJournal
+	}
+	if err != nil {
+		return "", err
+	}
+
+	names := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), extension) {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", ErrNoJournal
+	}
+	sort.Strings(names)
+	return filepath.Join(dir, names[len(names)-1]), nil
+}
+
+// Read returns the entries of a journal
+func Read(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid entry in %s line %d: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Undo renames back the files recorded in a journal, latest first, and
+// marks the journal as undone. Files that were moved or replaced since then
+// are left untouched and reported as failed, and the journal is rewritten
+// with only their entries so that they can be retried. Once ctx is done it
+// stops before the next file and returns the context error, leaving the
+// journal as it is.
+func Undo(ctx context.Context, path string, fsys filesystem.FS, logger *slog.Logger) ([]process.Result, error) {
+	if logger == nil {
+		logger = logging.Discard()
+	}
+	entries, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []process.Result{}
+	failed := []Entry{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		e := entries[i]
+		res := process.Result{Path: e.To, NewPath: e.From, Status: process.StatusRenamed}
+		if err := undoEntry(e, fsys); err != nil {
+			res.Status = process.StatusFailed
+			res.ErrorClass = process.ClassRename
+			res.Error = err.Error()
+			logger.Warn("Could not undo rename", "path", e.To, "newPath", e.From, "error", err)
+			failed = append([]Entry{e}, failed...)
+		} else {
+			logger.Info("Renamed", "path", e.To, "newPath", e.From)
+		}
+		results = append(results, res)
+	}
+
+	if len(failed) > 0 {
+		return results, rewrite(path, failed)
+	}
+	if err := os.Rename(path, path+undoneSuffix); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// rewrite replaces the entries of a journal, writing them to a temporary
+// file first so that the journal is never left half written
+func rewrite(path string, entries []Entry) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	for _, e := range entries {
+		if err := encoder.Encode(e); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// undoEntry renames back a single file, refusing to overwrite an existing one
+func undoEntry(e Entry, fsys filesystem.FS) error {
+	if _, err := fsys.Lstat(e.To); err != nil {
+		return fmt.Errorf("%w %s: %v", process.ErrRename, e.To, err)
+	}
+	if _, err := fsys.Lstat(e.From); err == nil {
+		return fmt.Errorf("%w %s: %s already exists", process.ErrRename, e.To, e.From)
+	}
+	return fsys.Rename(e.To, e.From)
+}
@@ -0,0 +1,173 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package journal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lluissm/media-renamer/internal/filesystem"
+	"github.com/lluissm/media-renamer/internal/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/state")
+	dir, err := Dir()
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join("/state", "media-renamer", "journal"), dir)
+}
+
+func TestRecordAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal", "run.jsonl")
+	j, err := Create(path)
+	assert.Nil(t, err)
+	assert.Nil(t, j.Record("/a/IMG_1.jpg", "/a/2022_10_03_10_15_00.jpg"))
+	assert.Nil(t, j.Record("/a/IMG_2.jpg", "/a/2022_10_03_10_16_00.jpg"))
+	assert.Nil(t, j.Close())
+
+	entries, err := Read(path)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "/a/IMG_1.jpg", entries[0].From)
+	assert.Equal(t, "/a/2022_10_03_10_16_00.jpg", entries[1].To)
+}
+
+func TestCloseRemovesEmptyJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	j, err := Create(path)
+	assert.Nil(t, err)
+	assert.Nil(t, j.Close())
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRenamerRecordsSuccessfulRenames(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "run.jsonl")
+	j, err := Create(path)
+	assert.Nil(t, err)
+	renamer := &Renamer{FS: filesystem.OS{}, Journal: j}
+
+	from := filepath.Join(dir, "IMG_1.jpg")
+	assert.Nil(t, os.WriteFile(from, nil, 0644))
+	assert.Nil(t, renamer.Rename(from, filepath.Join(dir, "renamed.jpg")))
+	assert.NotNil(t, renamer.Rename(filepath.Join(dir, "missing.jpg"), filepath.Join(dir, "other.jpg")))
+	assert.Nil(t, j.Close())
+
+	entries, err := Read(path)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, from, entries[0].From)
+}
+
+func TestLatest(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Latest(filepath.Join(dir, "missing"))
+	assert.ErrorIs(t, err, ErrNoJournal)
+
+	for _, name := range []string{"20221003T101500.jsonl", "20221004T090000.jsonl", "20221005T090000.jsonl.undone"} {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	latest, err := Latest(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "20221004T090000.jsonl"), latest)
+}
+
+func TestUndo(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "run.jsonl")
+	j, err := Create(path)
+	assert.Nil(t, err)
+	renamer := &Renamer{FS: filesystem.OS{}, Journal: j}
+
+	names := map[string]string{"IMG_1.jpg": "renamed_1.jpg", "IMG_2.jpg": "renamed_2.jpg", "IMG_3.jpg": "renamed_3.jpg"}
+	for from, to := range names {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, from), nil, 0644))
+		assert.Nil(t, renamer.Rename(filepath.Join(dir, from), filepath.Join(dir, to)))
+	}
+	assert.Nil(t, j.Close())
+
+	// A renamed file moved away and a new file taking the original name
+	assert.Nil(t, os.Remove(filepath.Join(dir, "renamed_2.jpg")))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "IMG_3.jpg"), nil, 0644))
+
+	results, err := Undo(context.Background(), path, filesystem.OS{}, nil)
+	assert.Nil(t, err)
+	assert.Len(t, results, 3)
+
+	statuses := map[string]process.Status{}
+	for _, res := range results {
+		statuses[filepath.Base(res.NewPath)] = res.Status
+	}
+	assert.Equal(t, process.StatusRenamed, statuses["IMG_1.jpg"])
+	assert.Equal(t, process.StatusFailed, statuses["IMG_2.jpg"])
+	assert.Equal(t, process.StatusFailed, statuses["IMG_3.jpg"])
+	assert.FileExists(t, filepath.Join(dir, "IMG_1.jpg"))
+	assert.FileExists(t, filepath.Join(dir, "renamed_3.jpg"))
+
+	// Only the failed entries are kept, to retry them
+	entries, err := Read(path)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	latest, err := Latest(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, path, latest)
+
+	// Once every entry is undone the journal is marked as undone
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "renamed_2.jpg"), nil, 0644))
+	assert.Nil(t, os.Remove(filepath.Join(dir, "IMG_3.jpg")))
+	results, err = Undo(context.Background(), path, filesystem.OS{}, nil)
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	for _, res := range results {
+		assert.Equal(t, process.StatusRenamed, res.Status, res.Path)
+	}
+	assert.FileExists(t, filepath.Join(dir, "IMG_2.jpg"))
+	assert.FileExists(t, filepath.Join(dir, "IMG_3.jpg"))
+
+	assert.NoFileExists(t, path)
+	assert.FileExists(t, path+".undone")
+	_, err = Latest(dir)
+	assert.ErrorIs(t, err, ErrNoJournal)
+}
+
+func TestUndo_Canceled(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "run.jsonl")
+	j, err := Create(path)
+	assert.Nil(t, err)
+	assert.Nil(t, j.Record(filepath.Join(dir, "IMG_1.jpg"), filepath.Join(dir, "renamed_1.jpg")))
+	assert.Nil(t, j.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := Undo(ctx, path, filesystem.OS{}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, results)
+	assert.FileExists(t, path)
+}
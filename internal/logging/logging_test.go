@@ -0,0 +1,73 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_Levels(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, "warn", FormatText)
+	assert.NoError(t, err)
+
+	logger.Info("hidden")
+	logger.Warn("shown", "path", "IMG_0001.jpeg")
+	assert.NotContains(t, out.String(), "hidden")
+	assert.Contains(t, out.String(), "level=WARN msg=shown path=IMG_0001.jpeg")
+
+	logger, err = New(&out, "DEBUG", FormatText)
+	assert.NoError(t, err)
+	assert.True(t, logger.Enabled(context.Background(), slog.LevelDebug))
+}
+
+func TestNew_JSON(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, "info", FormatJSON)
+	assert.NoError(t, err)
+
+	logger.Info("renamed", "path", "IMG_0001.jpeg")
+	var record map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, "renamed", record["msg"])
+	assert.Equal(t, "IMG_0001.jpeg", record["path"])
+}
+
+func TestNew_Errors(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "verbose", FormatText)
+	assert.Error(t, err)
+
+	_, err = New(&bytes.Buffer{}, "info", "xml")
+	assert.Error(t, err)
+}
+
+func TestDiscard(t *testing.T) {
+	assert.False(t, Discard().Enabled(context.Background(), slog.LevelError))
+}
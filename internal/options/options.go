@@ -24,61 +24,596 @@ SOFTWARE.
 package options
 
 import (
+	"bytes"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 )
 
 const cmdName = "media-renamer"
 
+// Commands
+const (
+	CommandRename        = "rename"
+	CommandPlan          = "plan"
+	CommandInspect       = "inspect"
+	CommandUndo          = "undo"
+	CommandVerify        = "verify"
+	CommandWatch         = "watch"
+	CommandConfigShow    = "config show"
+	CommandConfigSuggest = "config suggest"
+	CommandCachePrune    = "cache prune"
+	CommandVersion       = "version"
+	CommandHelp          = "help"
+)
+
 // Options are the process.Options parsed from command line flags/args
 type Options struct {
-	ShowVersion      bool
+	Command          string
 	Verbose          bool
-	Path             string
+	Paths            []string
+	FilesFrom        string
 	CustomConfigPath string
+	ResolvedConfig   bool
+	Exclude          []string
+	MaxDepth         int
+	Output           string
+	Reports          []string
+	LogLevel         string
+	LogFormat        string
+	LogFile          string
+	DryRun           bool
+	Interactive      bool
+	Quiet            bool
+	Journal          string
+	AllDates         bool
+	Samples          int
+	TrustNames       bool
+	OnCollision      string
+	SetMtime         bool
+	SetAtime         bool
+	SetTimesNamed    bool
+	WriteMetadata    bool
+	MetadataBackup   bool
+	WriteShifted     bool
+	Shift            time.Duration
+	SyncRefs         []SyncRef
+	NoCache          bool
+	Stable           time.Duration
+}
+
+// SyncRef is a pair of photos taken at the same time by two cameras, the
+// files of the camera of Target are put on the clock of the one of Reference
+type SyncRef struct {
+	Reference, Target string
+}
+
+// stringList is a flag that can be repeated, accumulating its values
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+type (
+	// command is a subcommand of the cli, with its own flags and help
+	command struct {
+		name        string
+		usage       string
+		summary     string
+		description string
+		examples    []string
+		flags       []flagGroup
+		args        func(o *Options, args []string) error
+	}
+
+	// flagGroup registers a set of flags shared by several commands and
+	// returns the function that stores their values in the Options
+	flagGroup func(flagSet *flag.FlagSet) func(o *Options) error
+)
+
+// commands are the available commands, in the order they are listed in the help
+var commands = []*command{
+	{
+		name:        CommandRename,
+		usage:       "[flags] PATH...",
+		summary:     "Rename photos and videos according to their metadata date",
+		description: "Renames the supported files in the given folders (recursively) and files to the date found in their metadata. This is the default command: \"media-renamer PATH\" is the same as \"media-renamer rename PATH\". The renames are recorded in a journal so that they can be reverted with the undo command.",
+		examples: []string{
+			"media-renamer ~/Desktop/my-trip",
+			"media-renamer rename -i ~/Desktop/my-trip ~/Desktop/IMG_0001.jpeg",
+			"media-renamer rename --set-mtime --set-times-named ~/Pictures",
+			"find . -name '*.heic' -print0 | media-renamer rename --files-from -",
+		},
+		flags: []flagGroup{renameFlags, interactiveFlags, namingFlags, cacheFlags, configFlags, selectionFlags, outputFlags, loggingFlags},
+		args:  pathArgs,
+	},
+	{
+		name:        CommandPlan,
+		usage:       "[flags] PATH...",
+		summary:     "Display the new names without renaming any file",
+		description: "Computes the new name of the supported files in the given folders and files, as rename would do, without touching them.",
+		examples: []string{
+			"media-renamer plan ~/Desktop/my-trip",
+			"media-renamer plan --output json ~/Desktop/my-trip > plan.json",
+		},
+		flags: []flagGroup{namingFlags, cacheFlags, configFlags, selectionFlags, outputFlags, loggingFlags},
+		args:  pathArgs,
+	},
+	{
+		name:        CommandInspect,
+		usage:       "[flags] FILE...",
+		summary:     "Show the date found in the metadata of files",
+		description: "Displays, for each file, its configured file type, every configured date field with its raw value and whether it parses, the field that is used and the resulting name.",
+		examples: []string{
+			"media-renamer inspect IMG_0001.jpeg",
+			"media-renamer inspect --all-dates IMG_0001.jpeg",
+			"media-renamer inspect --output json ~/Desktop/my-trip",
+		},
+		flags: []flagGroup{inspectFlags, configFlags, loggingFlags},
+		args:  pathArgs,
+	},
+	{
+		name:        CommandUndo,
+		usage:       "[flags] [JOURNAL]",
+		summary:     "Revert the renames of a previous run",
+		description: "Renames back the files renamed by a previous run, as recorded in its journal. Without arguments the journal of the latest run is used.",
+		examples: []string{
+			"media-renamer undo",
+			"media-renamer undo ~/.local/state/media-renamer/journal/20221003T101500.jsonl",
+		},
+		flags: []flagGroup{outputFlags, loggingFlags},
+		args: func(o *Options, args []string) error {
+			if len(args) > 1 {
+				return errors.New("undo accepts a single journal")
+			}
+			if len(args) == 1 {
+				o.Journal = args[0]
+			}
+			return nil
+		},
+	},
+	{
+		name:        CommandVerify,
+		usage:       "[flags] PATH...",
+		summary:     "Check that files are named according to their metadata",
+		description: "Lists the supported files whose name does not match the date in their metadata, without renaming them. Exits with status 1 if there is any.",
+		examples: []string{
+			"media-renamer verify ~/Pictures/archive",
+		},
+		flags: []flagGroup{cacheFlags, configFlags, selectionFlags, outputFlags, loggingFlags},
+		args:  pathArgs,
+	},
+	{
+		name:        CommandWatch,
+		usage:       "[flags] DIR",
+		summary:     "Rename the files added to a folder as they arrive",
+		description: "Watches DIR and its subfolders and renames the files already present and the ones added or modified, once they are stable: their size has not changed for the -stable interval and no process is writing to them. Runs until interrupted (SIGINT or SIGTERM).",
+		examples: []string{
+			"media-renamer watch ~/phone-backup/inbox",
+			"media-renamer watch -stable 10s --output ndjson ~/phone-backup/inbox",
+		},
+		flags: []flagGroup{watchFlags, renameFlags, namingFlags, cacheFlags, configFlags, selectionFlags, outputFlags, loggingFlags},
+		args: func(o *Options, args []string) error {
+			if len(args) != 1 || o.FilesFrom != "" {
+				return errors.New("watch requires a single folder")
+			}
+			o.Paths = args
+			return nil
+		},
+	},
+	{
+		name:        CommandConfigShow,
+		usage:       "[flags] [PATH]",
+		summary:     "Display the default or the effective configuration",
+		description: "Displays the configuration embedded in the binary or, with --resolved, the configuration that applies to PATH (the current folder by default) and the layer each setting comes from.",
+		examples: []string{
+			"media-renamer config show",
+			"media-renamer config show --resolved ~/Desktop/my-trip",
+		},
+		flags: []flagGroup{configShowFlags, configFlags, loggingFlags},
+		args: func(o *Options, args []string) error {
+			o.Paths = []string{"."}
+			if len(args) > 0 {
+				o.Paths = args[:1]
+			}
+			return nil
+		},
+	},
+	{
+		name:        CommandConfigSuggest,
+		usage:       "[flags] DIR",
+		summary:     "Suggest the configuration of the file types found in a folder",
+		description: "Samples files of each extension in DIR, finds the date tags in their metadata, infers their date format and prints the date fields ranked by the number of files they cover, as a configuration file ready to be used.",
+		examples: []string{
+			"media-renamer config suggest ~/Desktop/new-camera",
+			"media-renamer config suggest -samples 50 ~/Desktop/new-camera > .media-renamer.yml",
+		},
+		flags: []flagGroup{suggestFlags, loggingFlags},
+		args: func(o *Options, args []string) error {
+			if len(args) != 1 {
+				return errors.New("config suggest requires a single folder")
+			}
+			o.Paths = args
+			return nil
+		},
+	},
+	{
+		name:        CommandCachePrune,
+		usage:       "[flags]",
+		summary:     "Remove the cached dates of files that were deleted or changed",
+		description: "Removes from the cache of extracted dates the entries of files that no longer exist or have changed since they were cached.",
+		examples:    []string{"media-renamer cache prune"},
+		flags:       []flagGroup{loggingFlags},
+	},
+	{
+		name:        CommandVersion,
+		usage:       "",
+		summary:     "Display version number",
+		description: "Displays the version number.",
+		examples:    []string{"media-renamer version"},
+		flags:       []flagGroup{loggingFlags},
+	},
 }
 
 // Parse returns the parsed Options from command line flags/args
 func Parse(osArgs []string) (*Options, error) {
+	args := osArgs[1:]
+	if len(args) > 0 && (args[0] == CommandHelp || args[0] == "-h" || args[0] == "--help") {
+		return help(args[1:])
+	}
 
-	flagSet := flag.NewFlagSet("mrn", flag.ExitOnError)
-	flagSet.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "\033[1;4mSYNOPSIS\033[0m\n\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "%s ~/Desktop/my-trip\n\n", cmdName)
-		fmt.Fprintf(flag.CommandLine.Output(), "\033[1;4mOPTIONS\033[0m\n\n")
-		flagSet.PrintDefaults()
+	cmd, args := findCommand(args)
+	if cmd == nil {
+		printUsage(os.Stderr)
+		return nil, fmt.Errorf("unknown command %q", strings.Join(args, " "))
 	}
 
-	showVersionFlag := flagSet.Bool("version", false, "Display version number")
-	verboseFlag := flagSet.Bool("v", false, "Diplay detailed information of the processing during execution")
-	configFileFlag := flagSet.String("c", "", "Path to custom configuration file (optional)")
+	flagSet := flag.NewFlagSet(cmdName+" "+cmd.name, flag.ExitOnError)
+	flagSet.Usage = func() { cmd.printUsage(flagSet) }
+	apply := []func(*Options) error{}
+	for _, group := range cmd.flags {
+		apply = append(apply, group(flagSet))
+	}
+	// Backward compatible -version flag of the default command
+	showVersionFlag := false
+	if cmd.name == CommandRename {
+		flagSet.BoolVar(&showVersionFlag, "version", false, "Display version number")
+	}
 
-	if err := flagSet.Parse(osArgs[1:]); err != nil {
+	if err := flagSet.Parse(args); err != nil {
 		return nil, err
 	}
+	if showVersionFlag {
+		return &Options{Command: CommandVersion, LogLevel: "warn", LogFormat: "text"}, nil
+	}
+
+	o := &Options{Command: cmd.name}
+	for _, a := range apply {
+		if err := a(o); err != nil {
+			return nil, err
+		}
+	}
+	if cmd.args != nil {
+		if err := cmd.args(o, flagSet.Args()); err != nil {
+			return nil, err
+		}
+	} else if flagSet.NArg() > 0 {
+		return nil, fmt.Errorf("%s does not accept arguments", cmd.name)
+	}
+	return o, nil
+}
+
+// findCommand returns the command named in args and the remaining args. Any
+// other first argument is a path for the default rename command. Commands
+// with a space in their name (e.g., "config show") take two arguments.
+func findCommand(args []string) (*command, []string) {
+	if len(args) == 0 {
+		return commandByName(CommandRename), args
+	}
+	if len(args) > 1 {
+		if cmd := commandByName(args[0] + " " + args[1]); cmd != nil {
+			return cmd, args[2:]
+		}
+	}
+	if cmd := commandByName(args[0]); cmd != nil {
+		return cmd, args[1:]
+	}
+	if isCommandGroup(args[0]) {
+		return nil, args
+	}
+	return commandByName(CommandRename), args
+}
+
+func commandByName(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// isCommandGroup returns true if name is the first word of a command (e.g., "config")
+func isCommandGroup(name string) bool {
+	for _, cmd := range commands {
+		if strings.HasPrefix(cmd.name, name+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// help prints the usage of a command, or of the cli if none is given
+func help(args []string) (*Options, error) {
+	if cmd, _ := findCommand(args); len(args) > 0 && cmd != nil {
+		flagSet := flag.NewFlagSet(cmdName+" "+cmd.name, flag.ContinueOnError)
+		flagSet.SetOutput(os.Stdout)
+		for _, group := range cmd.flags {
+			group(flagSet)
+		}
+		cmd.printUsage(flagSet)
+	} else {
+		printUsage(os.Stdout)
+	}
+	return &Options{Command: CommandHelp, LogLevel: "warn", LogFormat: "text"}, nil
+}
+
+// printUsage prints the list of commands
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "\033[1;4mSYNOPSIS\033[0m\n\n")
+	fmt.Fprintf(w, "%s COMMAND [flags] [args]\n", cmdName)
+	fmt.Fprintf(w, "%s [flags] PATH... (same as rename)\n\n", cmdName)
+	fmt.Fprintf(w, "\033[1;4mCOMMANDS\033[0m\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s help COMMAND' for the flags and examples of a command.\n", cmdName)
+}
+
+// printUsage prints the help of a command
+func (cmd *command) printUsage(flagSet *flag.FlagSet) {
+	w := flagSet.Output()
+	fmt.Fprintf(w, "\033[1;4mSYNOPSIS\033[0m\n\n")
+	fmt.Fprintf(w, "%s %s %s\n\n", cmdName, cmd.name, cmd.usage)
+	fmt.Fprintf(w, "\033[1;4mDESCRIPTION\033[0m\n\n")
+	fmt.Fprintf(w, "%s\n\n", cmd.description)
+	fmt.Fprintf(w, "\033[1;4mEXAMPLES\033[0m\n\n")
+	for _, example := range cmd.examples {
+		fmt.Fprintf(w, "%s\n", example)
+	}
+	fmt.Fprintf(w, "\n\033[1;4mOPTIONS\033[0m\n\n")
+	flagSet.PrintDefaults()
+}
+
+// pathArgs stores the paths to process, required unless read from a file
+func pathArgs(o *Options, args []string) error {
+	if len(args) < 1 && o.FilesFrom == "" {
+		return errors.New("Missing arguments, please see documentation")
+	}
+	// The answers of -i are read from stdin
+	if o.Interactive && o.FilesFrom == "-" {
+		return errors.New("-i cannot be combined with -files-from -, read the paths from a file instead")
+	}
+	o.Paths = args
+	return nil
+}
+
+// renameFlags are the flags that control how files are renamed
+func renameFlags(flagSet *flag.FlagSet) func(o *Options) error {
+	dryRunFlag := flagSet.Bool("n", false, "Dry run: display the new names without renaming any file (optional)")
+	journalFlag := flagSet.String("journal", "", "Path of the journal recording the renames, a new one in the state folder by default (optional)")
+	setMtimeFlag := flagSet.Bool("set-mtime", false, "Set the modification time of the renamed files to their date (optional)")
+	setAtimeFlag := flagSet.Bool("set-atime", false, "Set the access time of the renamed files to their date (optional)")
+	setTimesNamedFlag := flagSet.Bool("set-times-named", false, "Also set the times of the files already named after their date (optional)")
+	writeMetadataFlag := flagSet.Bool("write-metadata", false, "Write the original name, and the missing standard dates, to the metadata of the renamed files (optional)")
+	metadataBackupFlag := flagSet.Bool("metadata-backup", false, "Keep a NAME_original copy of the files whose metadata is written (optional)")
+	writeShiftedFlag := flagSet.Bool("write-shifted", false, "Write the shifted dates to the metadata of the renamed files, requires -write-metadata (optional)")
+	return func(o *Options) error {
+		if *setTimesNamedFlag && !*setMtimeFlag && !*setAtimeFlag {
+			return errors.New("-set-times-named requires -set-mtime or -set-atime")
+		}
+		if *metadataBackupFlag && !*writeMetadataFlag {
+			return errors.New("-metadata-backup requires -write-metadata")
+		}
+		if *writeShiftedFlag && !*writeMetadataFlag {
+			return errors.New("-write-shifted requires -write-metadata")
+		}
+		o.DryRun = *dryRunFlag
+		o.Journal = *journalFlag
+		o.SetMtime = *setMtimeFlag
+		o.SetAtime = *setAtimeFlag
+		o.SetTimesNamed = *setTimesNamedFlag
+		o.WriteMetadata = *writeMetadataFlag
+		o.MetadataBackup = *metadataBackupFlag
+		o.WriteShifted = *writeShiftedFlag
+		return nil
+	}
+}
+
+// interactiveFlags are the flags of the commands that can confirm each rename
+func interactiveFlags(flagSet *flag.FlagSet) func(o *Options) error {
+	interactiveFlag := flagSet.Bool("i", false, "Interactive: confirm each rename before it is done (optional)")
+	return func(o *Options) error {
+		o.Interactive = *interactiveFlag
+		return nil
+	}
+}
 
-	args := flagSet.Args()
+// watchFlags are the flags of the watch command
+func watchFlags(flagSet *flag.FlagSet) func(o *Options) error {
+	stableFlag := flagSet.Duration("stable", 2*time.Second, "Time the size of a file must stay unchanged before it is renamed")
+	return func(o *Options) error {
+		if *stableFlag <= 0 {
+			return errors.New("stable must be positive")
+		}
+		o.Stable = *stableFlag
+		return nil
+	}
+}
 
-	if *showVersionFlag {
-		return &Options{
-			true,
-			true,
-			"",
-			"",
-		}, nil
+// namingFlags are the flags that control how files are named and how already
+// named and colliding files are handled
+func namingFlags(flagSet *flag.FlagSet) func(o *Options) error {
+	trustNamesFlag := flagSet.Bool("trust-names", false, "Skip the files already named after a date without reading their metadata (optional)")
+	onCollisionFlag := flagSet.String("on-collision", "suffix", "What to do when the new name is taken by another file: suffix (append _1, _2...), skip or overwrite")
+	shiftFlag := flagSet.Duration("shift", 0, "Add a duration to the date of every file, e.g., +1h30m or -45s, on top of the shift rules of the configuration (optional)")
+	var syncRefFlag stringList
+	flagSet.Var(&syncRefFlag, "sync-ref", "Put the files of the camera that took B on the clock of the one that took A, two photos taken at the same time given as A=B, can be repeated (optional)")
+	return func(o *Options) error {
+		switch *onCollisionFlag {
+		case "suffix", "skip", "overwrite":
+		default:
+			return fmt.Errorf("invalid -on-collision %q, valid values are suffix, skip and overwrite", *onCollisionFlag)
+		}
+		o.TrustNames = *trustNamesFlag
+		o.OnCollision = *onCollisionFlag
+		o.Shift = *shiftFlag
+		for _, value := range syncRefFlag {
+			reference, target, ok := strings.Cut(value, "=")
+			if !ok || reference == "" || target == "" {
+				return fmt.Errorf("invalid -sync-ref %q, expected two photos as A=B", value)
+			}
+			o.SyncRefs = append(o.SyncRefs, SyncRef{reference, target})
+		}
+		return nil
 	}
+}
 
-	if len(args) < 1 {
-		return nil, errors.New("Missing arguments, please see documentation")
+// cacheFlags are the flags that control the cache of extracted dates
+func cacheFlags(flagSet *flag.FlagSet) func(o *Options) error {
+	noCacheFlag := flagSet.Bool("no-cache", false, "Extract the metadata of every file, without using or updating the cache (optional)")
+	return func(o *Options) error {
+		o.NoCache = *noCacheFlag
+		return nil
 	}
+}
 
-	path := args[0]
+// configFlags are the flags that select the configuration
+func configFlags(flagSet *flag.FlagSet) func(o *Options) error {
+	configFileFlag := flagSet.String("c", "", "Path to custom configuration file (optional)")
+	return func(o *Options) error {
+		o.CustomConfigPath = *configFileFlag
+		return nil
+	}
+}
+
+// inspectFlags are the flags of the inspect command
+func inspectFlags(flagSet *flag.FlagSet) func(o *Options) error {
+	allDatesFlag := flagSet.Bool("all-dates", false, "List every date-like tag in the metadata, even if not configured (optional)")
+	outputFlag := flagSet.String("output", "text", "Output format: text, json (single document) or ndjson (one inspection per line)")
+	return func(o *Options) error {
+		o.AllDates = *allDatesFlag
+		o.Output = *outputFlag
+		return nil
+	}
+}
 
-	return &Options{
-		*showVersionFlag,
-		*verboseFlag,
-		path,
-		*configFileFlag,
-	}, nil
+// suggestFlags are the flags of the config suggest command
+func suggestFlags(flagSet *flag.FlagSet) func(o *Options) error {
+	samplesFlag := flagSet.Int("samples", 20, "Maximum number of files sampled per extension")
+	return func(o *Options) error {
+		if *samplesFlag < 1 {
+			return errors.New("samples must be at least 1")
+		}
+		o.Samples = *samplesFlag
+		return nil
+	}
+}
+
+// configShowFlags are the flags of the config show command
+func configShowFlags(flagSet *flag.FlagSet) func(o *Options) error {
+	resolvedFlag := flagSet.Bool("resolved", false, "Display the effective configuration and where each file type comes from")
+	return func(o *Options) error {
+		o.ResolvedConfig = *resolvedFlag
+		return nil
+	}
+}
+
+// selectionFlags are the flags that select the files to process
+func selectionFlags(flagSet *flag.FlagSet) func(o *Options) error {
+	var excludeFlag stringList
+	flagSet.Var(&excludeFlag, "exclude", "Glob pattern of files and folders to skip, can be repeated (optional)")
+	maxDepthFlag := flagSet.Int("max-depth", 0, "Maximum depth of the processed files, 1 being the files directly in the folder, 0 for unlimited (optional)")
+	noRecursiveFlag := flagSet.Bool("no-recursive", false, "Only process the files directly in the folder, same as -max-depth 1 (optional)")
+	filesFromFlag := flagSet.String("files-from", "", "Read newline or NUL separated paths to process from a file, - for stdin (optional)")
+	return func(o *Options) error {
+		if *maxDepthFlag < 0 {
+			return errors.New("max-depth cannot be negative")
+		}
+		o.MaxDepth = *maxDepthFlag
+		if *noRecursiveFlag {
+			o.MaxDepth = 1
+		}
+		o.Exclude = excludeFlag
+		o.FilesFrom = *filesFromFlag
+		return nil
+	}
+}
+
+// outputFlags are the flags that control the output of the results
+func outputFlags(flagSet *flag.FlagSet) func(o *Options) error {
+	outputFlag := flagSet.String("output", "text", "Output format: text, json (single document) or ndjson (one event per file)")
+	var reportFlag stringList
+	flagSet.Var(&reportFlag, "report", "Write a csv or html report of the run to this path, can be repeated (optional)")
+	quietFlag := flagSet.Bool("quiet", false, "Do not display the progress of the run (optional)")
+	return func(o *Options) error {
+		o.Output = *outputFlag
+		o.Reports = reportFlag
+		o.Quiet = *quietFlag
+		return nil
+	}
+}
+
+// loggingFlags are the flags that control the logs
+func loggingFlags(flagSet *flag.FlagSet) func(o *Options) error {
+	verboseFlag := flagSet.Bool("v", false, "Diplay detailed information of the processing during execution, same as -log-level debug")
+	logLevelFlag := flagSet.String("log-level", "warn", "Minimum level of the logs: debug, info, warn or error")
+	logFormatFlag := flagSet.String("log-format", "text", "Format of the logs: text or json")
+	logFileFlag := flagSet.String("log-file", "", "Write the logs to this file instead of stderr (optional)")
+	return func(o *Options) error {
+		o.Verbose = *verboseFlag
+		o.LogLevel = *logLevelFlag
+		if *verboseFlag {
+			o.LogLevel = "debug"
+		}
+		o.LogFormat = *logFormatFlag
+		o.LogFile = *logFileFlag
+		return nil
+	}
+}
+
+// ReadFileList returns the paths listed in r, separated by NUL characters
+// (as written by find -print0) if there is any or by newlines otherwise
+func ReadFileList(r io.Reader) ([]string, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	separator := byte('\n')
+	if bytes.IndexByte(content, 0) >= 0 {
+		separator = 0
+	}
+
+	paths := []string{}
+	for _, entry := range bytes.Split(content, []byte{separator}) {
+		path := string(entry)
+		if separator == '\n' {
+			path = strings.TrimSuffix(path, "\r")
+		}
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
 }
@@ -24,7 +24,9 @@ SOFTWARE.
 package options
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -46,19 +48,60 @@ func TestVersion(t *testing.T) {
 	args := []string{cmdName, versionFlagName, filePathArg}
 	options, err := Parse(args)
 	assert.Nil(t, err)
-	assert.True(t, options.ShowVersion)
+	assert.Equal(t, CommandVersion, options.Command)
+
+	args = []string{cmdName, "version"}
+	options, err = Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, CommandVersion, options.Command)
 
 	args = []string{cmdName, filePathArg}
 	options, err = Parse(args)
 	assert.Nil(t, err)
-	assert.False(t, options.ShowVersion)
+	assert.Equal(t, CommandRename, options.Command)
 }
 
 func TestPath(t *testing.T) {
 	args := []string{cmdName, filePathArg}
 	options, err := Parse(args)
 	assert.Nil(t, err)
-	assert.True(t, options.Path == filePathArg)
+	assert.Equal(t, []string{filePathArg}, options.Paths)
+}
+
+func TestMultiplePaths(t *testing.T) {
+	args := []string{cmdName, filePathArg, "another-path", "IMG_0001.jpeg"}
+	options, err := Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filePathArg, "another-path", "IMG_0001.jpeg"}, options.Paths)
+}
+
+func TestFilesFrom(t *testing.T) {
+	// No positional path needed
+	args := []string{cmdName, "--files-from", "-"}
+	options, err := Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, "-", options.FilesFrom)
+	assert.Empty(t, options.Paths)
+
+	// Stdin cannot hold both the paths and the answers of -i
+	_, err = Parse([]string{cmdName, "-i", "--files-from", "-"})
+	assert.Error(t, err)
+	_, err = Parse([]string{cmdName, "-i", "--files-from", "paths.txt"})
+	assert.Nil(t, err)
+}
+
+func TestReadFileList(t *testing.T) {
+	paths, err := ReadFileList(strings.NewReader("a.jpeg\nfolder with spaces\r\n\nb.mov"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.jpeg", "folder with spaces", "b.mov"}, paths)
+
+	paths, err = ReadFileList(strings.NewReader("./a.jpeg\x00./name\nwith newline.mov\x00"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"./a.jpeg", "./name\nwith newline.mov"}, paths)
+
+	paths, err = ReadFileList(strings.NewReader(""))
+	assert.Nil(t, err)
+	assert.Empty(t, paths)
 }
 
 func TestVerbose(t *testing.T) {
@@ -85,3 +128,315 @@ func TestCustomConfigFile(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "", options.CustomConfigPath)
 }
+
+func TestConfigShow(t *testing.T) {
+	args := []string{cmdName, "config", "show"}
+	options, err := Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, CommandConfigShow, options.Command)
+	assert.False(t, options.ResolvedConfig)
+	assert.Equal(t, []string{"."}, options.Paths)
+
+	args = []string{cmdName, "config", "show", "--resolved", "-c", "custom.yml", filePathArg}
+	options, err = Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, CommandConfigShow, options.Command)
+	assert.True(t, options.ResolvedConfig)
+	assert.Equal(t, []string{filePathArg}, options.Paths)
+	assert.Equal(t, "custom.yml", options.CustomConfigPath)
+
+	// config needs a subcommand
+	args = []string{cmdName, "config"}
+	_, err = Parse(args)
+	assert.NotNil(t, err)
+}
+
+func TestExclude(t *testing.T) {
+	args := []string{cmdName, "--exclude", "**/.thumbnails", "--exclude", "@eaDir", filePathArg}
+	options, err := Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"**/.thumbnails", "@eaDir"}, options.Exclude)
+
+	args = []string{cmdName, filePathArg}
+	options, err = Parse(args)
+	assert.Nil(t, err)
+	assert.Empty(t, options.Exclude)
+}
+
+func TestMaxDepth(t *testing.T) {
+	args := []string{cmdName, "--max-depth", "3", filePathArg}
+	options, err := Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, options.MaxDepth)
+
+	args = []string{cmdName, "--no-recursive", filePathArg}
+	options, err = Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, options.MaxDepth)
+
+	args = []string{cmdName, filePathArg}
+	options, err = Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, options.MaxDepth)
+
+	args = []string{cmdName, "--max-depth", "-1", filePathArg}
+	_, err = Parse(args)
+	assert.NotNil(t, err)
+}
+
+func TestOutput(t *testing.T) {
+	args := []string{cmdName, "--output", "ndjson", filePathArg}
+	options, err := Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, "ndjson", options.Output)
+
+	args = []string{cmdName, filePathArg}
+	options, err = Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, "text", options.Output)
+}
+
+func TestReports(t *testing.T) {
+	args := []string{cmdName, "--report", "report.csv", "--report", "report.html", filePathArg}
+	options, err := Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"report.csv", "report.html"}, options.Reports)
+}
+
+func TestLogging(t *testing.T) {
+	args := []string{cmdName, "--log-level", "info", "--log-format", "json", "--log-file", "run.log", filePathArg}
+	options, err := Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, "info", options.LogLevel)
+	assert.Equal(t, "json", options.LogFormat)
+	assert.Equal(t, "run.log", options.LogFile)
+
+	// Verbose is the debug level
+	args = []string{cmdName, "-v", filePathArg}
+	options, err = Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, "debug", options.LogLevel)
+
+	args = []string{cmdName, filePathArg}
+	options, err = Parse(args)
+	assert.Nil(t, err)
+	assert.Equal(t, "warn", options.LogLevel)
+	assert.Equal(t, "text", options.LogFormat)
+	assert.Equal(t, "", options.LogFile)
+}
+
+func TestDryRunAndInteractive(t *testing.T) {
+	args := []string{cmdName, "-n", "-i", filePathArg}
+	options, err := Parse(args)
+	assert.Nil(t, err)
+	assert.True(t, options.DryRun)
+	assert.True(t, options.Interactive)
+
+	args = []string{cmdName, filePathArg}
+	options, err = Parse(args)
+	assert.Nil(t, err)
+	assert.False(t, options.DryRun)
+	assert.False(t, options.Interactive)
+}
+
+func TestQuiet(t *testing.T) {
+	args := []string{cmdName, "--quiet", filePathArg}
+	options, err := Parse(args)
+	assert.Nil(t, err)
+	assert.True(t, options.Quiet)
+
+	args = []string{cmdName, filePathArg}
+	options, err = Parse(args)
+	assert.Nil(t, err)
+	assert.False(t, options.Quiet)
+}
+
+func TestCommands(t *testing.T) {
+	// The default command is rename
+	options, err := Parse([]string{cmdName, "-n", filePathArg})
+	assert.Nil(t, err)
+	assert.Equal(t, CommandRename, options.Command)
+	assert.True(t, options.DryRun)
+
+	options, err = Parse([]string{cmdName, "rename", "--journal", "run.jsonl", filePathArg})
+	assert.Nil(t, err)
+	assert.Equal(t, CommandRename, options.Command)
+	assert.Equal(t, "run.jsonl", options.Journal)
+	assert.Equal(t, []string{filePathArg}, options.Paths)
+
+	for _, name := range []string{CommandPlan, CommandVerify, CommandInspect} {
+		options, err = Parse([]string{cmdName, name, "-v", filePathArg})
+		assert.Nil(t, err)
+		assert.Equal(t, name, options.Command)
+		assert.Equal(t, []string{filePathArg}, options.Paths)
+		assert.Equal(t, "debug", options.LogLevel)
+
+		// Paths are required
+		_, err = Parse([]string{cmdName, name})
+		assert.NotNil(t, err)
+	}
+
+	// A folder with the name of a command can still be renamed
+	options, err = Parse([]string{cmdName, "rename", "plan"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"plan"}, options.Paths)
+}
+
+func TestUndo(t *testing.T) {
+	options, err := Parse([]string{cmdName, "undo"})
+	assert.Nil(t, err)
+	assert.Equal(t, CommandUndo, options.Command)
+	assert.Equal(t, "", options.Journal)
+
+	options, err = Parse([]string{cmdName, "undo", "run.jsonl"})
+	assert.Nil(t, err)
+	assert.Equal(t, "run.jsonl", options.Journal)
+
+	_, err = Parse([]string{cmdName, "undo", "a.jsonl", "b.jsonl"})
+	assert.NotNil(t, err)
+}
+
+func TestInspect(t *testing.T) {
+	options, err := Parse([]string{cmdName, "inspect", "--all-dates", "--output", "json", "a.jpeg"})
+	assert.Nil(t, err)
+	assert.Equal(t, CommandInspect, options.Command)
+	assert.True(t, options.AllDates)
+	assert.Equal(t, "json", options.Output)
+	assert.Equal(t, []string{"a.jpeg"}, options.Paths)
+
+	options, err = Parse([]string{cmdName, "inspect", "a.jpeg"})
+	assert.Nil(t, err)
+	assert.False(t, options.AllDates)
+	assert.Equal(t, "text", options.Output)
+}
+
+func TestConfigSuggest(t *testing.T) {
+	options, err := Parse([]string{cmdName, "config", "suggest", "-samples", "5", "dir"})
+	assert.Nil(t, err)
+	assert.Equal(t, CommandConfigSuggest, options.Command)
+	assert.Equal(t, 5, options.Samples)
+	assert.Equal(t, []string{"dir"}, options.Paths)
+
+	options, err = Parse([]string{cmdName, "config", "suggest", "dir"})
+	assert.Nil(t, err)
+	assert.Equal(t, 20, options.Samples)
+
+	_, err = Parse([]string{cmdName, "config", "suggest"})
+	assert.NotNil(t, err)
+	_, err = Parse([]string{cmdName, "config", "suggest", "-samples", "0", "dir"})
+	assert.NotNil(t, err)
+}
+
+func TestTrustNames(t *testing.T) {
+	options, err := Parse([]string{cmdName, "--trust-names", "dir"})
+	assert.Nil(t, err)
+	assert.True(t, options.TrustNames)
+
+	options, err = Parse([]string{cmdName, "plan", "--trust-names", "dir"})
+	assert.Nil(t, err)
+	assert.True(t, options.TrustNames)
+
+	options, err = Parse([]string{cmdName, "verify", "dir"})
+	assert.Nil(t, err)
+	assert.False(t, options.TrustNames)
+}
+
+func TestSetTimes(t *testing.T) {
+	options, err := Parse([]string{cmdName, "--set-mtime", "--set-times-named", "dir"})
+	assert.Nil(t, err)
+	assert.True(t, options.SetMtime)
+	assert.False(t, options.SetAtime)
+	assert.True(t, options.SetTimesNamed)
+
+	options, err = Parse([]string{cmdName, "watch", "--set-atime", "dir"})
+	assert.Nil(t, err)
+	assert.True(t, options.SetAtime)
+
+	_, err = Parse([]string{cmdName, "--set-times-named", "dir"})
+	assert.Error(t, err)
+}
+
+func TestWriteMetadata(t *testing.T) {
+	options, err := Parse([]string{cmdName, "--write-metadata", "--metadata-backup", "dir"})
+	assert.Nil(t, err)
+	assert.True(t, options.WriteMetadata)
+	assert.True(t, options.MetadataBackup)
+
+	_, err = Parse([]string{cmdName, "--metadata-backup", "dir"})
+	assert.Error(t, err)
+}
+
+func TestShift(t *testing.T) {
+	options, err := Parse([]string{cmdName, "plan", "--shift", "+1h30m", "dir"})
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Minute, options.Shift)
+
+	options, err = Parse([]string{cmdName, "--shift", "-45s", "--write-metadata", "--write-shifted", "dir"})
+	assert.Nil(t, err)
+	assert.Equal(t, -45*time.Second, options.Shift)
+	assert.True(t, options.WriteShifted)
+
+	_, err = Parse([]string{cmdName, "--write-shifted", "dir"})
+	assert.Error(t, err)
+}
+
+func TestSyncRef(t *testing.T) {
+	options, err := Parse([]string{cmdName, "--sync-ref", "a.jpg=b.jpg", "--sync-ref", "a.jpg=c.jpg", "dir"})
+	assert.Nil(t, err)
+	assert.Equal(t, []SyncRef{{"a.jpg", "b.jpg"}, {"a.jpg", "c.jpg"}}, options.SyncRefs)
+
+	_, err = Parse([]string{cmdName, "--sync-ref", "a.jpg", "dir"})
+	assert.Error(t, err)
+	_, err = Parse([]string{cmdName, "--sync-ref", "=b.jpg", "dir"})
+	assert.Error(t, err)
+}
+
+func TestOnCollision(t *testing.T) {
+	options, err := Parse([]string{cmdName, "dir"})
+	assert.Nil(t, err)
+	assert.Equal(t, "suffix", options.OnCollision)
+
+	options, err = Parse([]string{cmdName, "plan", "--on-collision", "skip", "dir"})
+	assert.Nil(t, err)
+	assert.Equal(t, "skip", options.OnCollision)
+
+	_, err = Parse([]string{cmdName, "--on-collision", "rename", "dir"})
+	assert.Error(t, err)
+}
+
+func TestCache(t *testing.T) {
+	options, err := Parse([]string{cmdName, "--no-cache", "dir"})
+	assert.Nil(t, err)
+	assert.True(t, options.NoCache)
+
+	options, err = Parse([]string{cmdName, "verify", "dir"})
+	assert.Nil(t, err)
+	assert.False(t, options.NoCache)
+
+	options, err = Parse([]string{cmdName, "cache", "prune"})
+	assert.Nil(t, err)
+	assert.Equal(t, CommandCachePrune, options.Command)
+
+	_, err = Parse([]string{cmdName, "cache"})
+	assert.NotNil(t, err)
+}
+
+func TestWatch(t *testing.T) {
+	options, err := Parse([]string{cmdName, "watch", "-stable", "10s", "-n", "inbox"})
+	assert.Nil(t, err)
+	assert.Equal(t, CommandWatch, options.Command)
+	assert.Equal(t, 10*time.Second, options.Stable)
+	assert.True(t, options.DryRun)
+	assert.Equal(t, []string{"inbox"}, options.Paths)
+
+	options, err = Parse([]string{cmdName, "watch", "inbox"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Second, options.Stable)
+
+	_, err = Parse([]string{cmdName, "watch"})
+	assert.NotNil(t, err)
+	_, err = Parse([]string{cmdName, "watch", "a", "b"})
+	assert.NotNil(t, err)
+	_, err = Parse([]string{cmdName, "watch", "-stable", "0s", "inbox"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,148 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/lluissm/media-renamer/internal/process"
+)
+
+// InspectionWriter writes the inspections of the inspect command
+type InspectionWriter interface {
+	// Inspection is called with the inspection of each file
+	Inspection(ins process.Inspection) error
+	// Close flushes the inspections not written yet
+	Close() error
+}
+
+// NewInspectionWriter returns the InspectionWriter for a format
+func NewInspectionWriter(format string, w io.Writer) (InspectionWriter, error) {
+	switch format {
+	case FormatText:
+		return &textInspectionWriter{w: w}, nil
+	case FormatJSON:
+		return &jsonInspectionWriter{w: w, inspections: []process.Inspection{}}, nil
+	case FormatNDJSON:
+		return &ndjsonInspectionWriter{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// textInspectionWriter writes a human readable block per file
+type textInspectionWriter struct {
+	w io.Writer
+}
+
+func (w *textInspectionWriter) Inspection(ins process.Inspection) error {
+	p := &printer{w: w.w}
+	p.printf("%s\n", ins.Path)
+	if ins.Source != "" {
+		p.printf("  file type: %s (%s)\n", ins.Extension, ins.Source)
+	}
+	if len(ins.Candidates) > 0 {
+		p.printf("  date fields:\n")
+	}
+	// The first date field that parses is the one used
+	used := false
+	for _, c := range ins.Candidates {
+		switch {
+		case !c.Present:
+			p.printf("    - %s: not present\n", c.Field)
+		case c.Time == nil:
+			p.printf("    x %s = %q: does not parse as %q: %s\n", c.Field, c.Raw, c.DateFormat, c.Error)
+		case !used:
+			used = true
+			p.printf("    * %s = %q -> %s (used)\n", c.Field, c.Raw, c.Name)
+		default:
+			p.printf("    + %s = %q -> %s\n", c.Field, c.Raw, c.Name)
+		}
+	}
+	if len(ins.Dates) > 0 {
+		p.printf("  all dates:\n")
+		for _, tag := range ins.Dates {
+			configured := ""
+			if tag.Configured {
+				configured = " (configured)"
+			}
+			p.printf("    %s = %q%s\n", tag.Name, tag.Value, configured)
+		}
+	}
+	switch {
+	case ins.Error != "":
+		p.printf("  error: %s\n", ins.Error)
+	case ins.NewName != "" && ins.Shift != "":
+		p.printf("  new name: %s (shifted %s)\n", ins.NewName, ins.Shift)
+	case ins.NewName != "":
+		p.printf("  new name: %s\n", ins.NewName)
+	default:
+		p.printf("  new name: none, no configured date field could be used\n")
+	}
+	return p.err
+}
+
+func (w *textInspectionWriter) Close() error { return nil }
+
+// jsonInspectionWriter writes a single document with all the inspections
+type jsonInspectionWriter struct {
+	w           io.Writer
+	inspections []process.Inspection
+}
+
+func (w *jsonInspectionWriter) Inspection(ins process.Inspection) error {
+	w.inspections = append(w.inspections, ins)
+	return nil
+}
+
+func (w *jsonInspectionWriter) Close() error {
+	encoder := json.NewEncoder(w.w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(w.inspections)
+}
+
+// ndjsonInspectionWriter writes one inspection per line
+type ndjsonInspectionWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonInspectionWriter) Inspection(ins process.Inspection) error {
+	return w.encoder.Encode(ins)
+}
+
+func (w *ndjsonInspectionWriter) Close() error { return nil }
+
+// printer writes formatted text, keeping the first error
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(format string, args ...any) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
@@ -0,0 +1,88 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lluissm/media-renamer/internal/process"
+	"github.com/stretchr/testify/assert"
+)
+
+var inspection = process.Inspection{
+	Path:      "IMG_0001.jpeg",
+	Extension: ".jpeg",
+	Source:    "defaults",
+	Candidates: []process.Candidate{
+		{Field: "DateTimeOriginal", DateFormat: "2006:01:02 15:04:05"},
+		{Field: "CreateDate", DateFormat: "2006:01:02 15:04:05", Present: true, Raw: "wrong", Error: "cannot parse"},
+		{Field: "ModifyDate", DateFormat: "2006:01:02 15:04:05", Present: true, Raw: "2019:08:05 14:12:13", Time: &date, Name: "2019_08_05_14_12_13"},
+		{Field: "FileModifyDate", DateFormat: "2006:01:02 15:04:05", Present: true, Raw: "2019:08:05 14:12:14", Time: &date, Name: "2019_08_05_14_12_14"},
+	},
+	Winner:  "ModifyDate",
+	NewName: "2019_08_05_14_12_13.jpeg",
+	Dates:   []process.Tag{{Name: "ModifyDate", Value: "2019:08:05 14:12:13", Configured: true}},
+}
+
+func writeInspection(t *testing.T, format string, ins ...process.Inspection) string {
+	var out bytes.Buffer
+	w, err := NewInspectionWriter(format, &out)
+	assert.NoError(t, err)
+	for _, i := range ins {
+		assert.NoError(t, w.Inspection(i))
+	}
+	assert.NoError(t, w.Close())
+	return out.String()
+}
+
+func TestInspectionText(t *testing.T) {
+	assert.Equal(t, `IMG_0001.jpeg
+  file type: .jpeg (defaults)
+  date fields:
+    - DateTimeOriginal: not present
+    x CreateDate = "wrong": does not parse as "2006:01:02 15:04:05": cannot parse
+    * ModifyDate = "2019:08:05 14:12:13" -> 2019_08_05_14_12_13 (used)
+    + FileModifyDate = "2019:08:05 14:12:14" -> 2019_08_05_14_12_14
+  all dates:
+    ModifyDate = "2019:08:05 14:12:13" (configured)
+  new name: 2019_08_05_14_12_13.jpeg
+`, writeInspection(t, FormatText, inspection))
+
+	assert.Equal(t, "IMG_0001.txt\n  error: unsupported\n",
+		writeInspection(t, FormatText, process.Inspection{Path: "IMG_0001.txt", Error: "unsupported"}))
+}
+
+func TestInspectionJSON(t *testing.T) {
+	var doc []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(writeInspection(t, FormatJSON, inspection, inspection)), &doc))
+	assert.Len(t, doc, 2)
+	assert.Equal(t, "ModifyDate", doc[0]["winner"])
+	assert.Len(t, doc[0]["candidates"], 4)
+
+	lines := strings.Split(strings.TrimSpace(writeInspection(t, FormatNDJSON, inspection, inspection)), "\n")
+	assert.Len(t, lines, 2)
+}
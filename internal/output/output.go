@@ -0,0 +1,150 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/lluissm/media-renamer/internal/process"
+)
+
+// Formats of the machine readable output
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// Writer writes the results of a run
+type Writer interface {
+	// Result is called with the result of each processed file
+	Result(res process.Result) error
+	// Close writes the summary of the run
+	Close() error
+}
+
+// Summary counts the results of a run
+type Summary struct {
+	Total    int                        `json:"total"`
+	ByStatus map[process.Status]int     `json:"byStatus"`
+	ByErrors map[process.ErrorClass]int `json:"byErrorClass"`
+}
+
+// New returns the Writer for a format
+func New(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatText:
+		return &textWriter{w: w}, nil
+	case FormatJSON:
+		return &jsonWriter{w: w, results: []process.Result{}, summary: newSummary()}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w), summary: newSummary()}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+func newSummary() *Summary {
+	return &Summary{
+		ByStatus: map[process.Status]int{},
+		ByErrors: map[process.ErrorClass]int{},
+	}
+}
+
+// add counts a result in the summary
+func (s *Summary) add(res process.Result) {
+	s.Total++
+	s.ByStatus[res.Status]++
+	if res.ErrorClass != "" {
+		s.ByErrors[res.ErrorClass]++
+	}
+}
+
+// textWriter only writes the renames planned in a dry run, the rest of
+// results are logged
+type textWriter struct {
+	w io.Writer
+}
+
+func (w *textWriter) Result(res process.Result) error {
+	if res.Status != process.StatusPlanned {
+		return nil
+	}
+	_, err := fmt.Fprintf(w.w, "%s -> %s\n", res.Path, res.NewPath)
+	return err
+}
+
+func (w *textWriter) Close() error { return nil }
+
+// jsonWriter writes a single document with all the results and the summary
+type jsonWriter struct {
+	w       io.Writer
+	results []process.Result
+	summary *Summary
+}
+
+func (w *jsonWriter) Result(res process.Result) error {
+	w.results = append(w.results, res)
+	w.summary.add(res)
+	return nil
+}
+
+func (w *jsonWriter) Close() error {
+	encoder := json.NewEncoder(w.w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Results []process.Result `json:"results"`
+		Summary *Summary         `json:"summary"`
+	}{w.results, w.summary})
+}
+
+// ndjsonWriter writes one event per line as files are processed, followed
+// by a summary event
+type ndjsonWriter struct {
+	encoder *json.Encoder
+	summary *Summary
+}
+
+// event types of the ndjson output
+const (
+	eventFile    = "file"
+	eventSummary = "summary"
+)
+
+func (w *ndjsonWriter) Result(res process.Result) error {
+	w.summary.add(res)
+	return w.encoder.Encode(struct {
+		Type string `json:"type"`
+		process.Result
+	}{eventFile, res})
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.encoder.Encode(struct {
+		Type string `json:"type"`
+		*Summary
+	}{eventSummary, w.summary})
+}
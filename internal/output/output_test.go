@@ -0,0 +1,116 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/process"
+	"github.com/stretchr/testify/assert"
+)
+
+var date = time.Date(2019, 8, 5, 14, 12, 13, 0, time.UTC)
+
+var results = []process.Result{
+	{
+		Path:      "IMG_0001.jpeg",
+		NewPath:   "2019_08_05_14_12_13.jpeg",
+		Status:    process.StatusRenamed,
+		DateField: "CreateDate",
+		RawDate:   "2019:08:05 14:12:13",
+		Time:      &date,
+	},
+	{
+		Path:       "IMG_0002.jpeg",
+		Status:     process.StatusFailed,
+		ErrorClass: process.ClassNoDate,
+		Error:      "no date",
+	},
+}
+
+func write(t *testing.T, format string) string {
+	var out bytes.Buffer
+	w, err := New(format, &out)
+	assert.NoError(t, err)
+	for _, res := range results {
+		assert.NoError(t, w.Result(res))
+	}
+	assert.NoError(t, w.Close())
+	return out.String()
+}
+
+func TestNew_UnknownFormat(t *testing.T) {
+	_, err := New("xml", &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestText(t *testing.T) {
+	assert.Equal(t, "", write(t, FormatText))
+
+	// Planned renames of a dry run are listed
+	var out bytes.Buffer
+	w, err := New(FormatText, &out)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Result(process.Result{Path: "IMG_0001.jpeg", NewPath: "2019_08_05_14_12_13.jpeg", Status: process.StatusPlanned}))
+	assert.Equal(t, "IMG_0001.jpeg -> 2019_08_05_14_12_13.jpeg\n", out.String())
+}
+
+func TestJSON(t *testing.T) {
+	var doc struct {
+		Results []map[string]interface{} `json:"results"`
+		Summary Summary                  `json:"summary"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(write(t, FormatJSON)), &doc))
+
+	assert.Equal(t, 2, len(doc.Results))
+	assert.Equal(t, "2019_08_05_14_12_13.jpeg", doc.Results[0]["newPath"])
+	assert.Equal(t, "CreateDate", doc.Results[0]["dateField"])
+	assert.Equal(t, "2019-08-05T14:12:13Z", doc.Results[0]["time"])
+	assert.NotContains(t, doc.Results[0], "error")
+	assert.Equal(t, "no-date", doc.Results[1]["errorClass"])
+
+	assert.Equal(t, 2, doc.Summary.Total)
+	assert.Equal(t, 1, doc.Summary.ByStatus[process.StatusRenamed])
+	assert.Equal(t, 1, doc.Summary.ByStatus[process.StatusFailed])
+	assert.Equal(t, 1, doc.Summary.ByErrors[process.ClassNoDate])
+}
+
+func TestNDJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(write(t, FormatNDJSON)), "\n")
+	assert.Equal(t, 3, len(lines))
+
+	var event map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &event))
+	assert.Equal(t, "file", event["type"])
+	assert.Equal(t, "IMG_0001.jpeg", event["path"])
+	assert.Equal(t, "renamed", event["status"])
+
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &event))
+	assert.Equal(t, "summary", event["type"])
+	assert.Equal(t, float64(2), event["total"])
+}
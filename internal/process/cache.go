@@ -0,0 +1,128 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/config"
+	"github.com/lluissm/media-renamer/internal/filesystem"
+)
+
+// Cache remembers the date extracted from the metadata of files in previous
+// runs, so that unchanged files are not extracted again
+type Cache interface {
+	// Get returns the entry of a file if it has not changed since it was
+	// stored with the same configuration fingerprint
+	Get(path string, info fs.FileInfo, fingerprint string) (CacheEntry, bool)
+	// Put stores the entry of a file
+	Put(path string, info fs.FileInfo, fingerprint string, entry CacheEntry)
+}
+
+// CacheEntry is the date found in the metadata of a file, or why none was found
+type CacheEntry struct {
+	DateField  string     `json:"dateField,omitempty"`
+	RawDate    string     `json:"rawDate,omitempty"`
+	Time       *time.Time `json:"time,omitempty"`
+	Shift      string     `json:"shift,omitempty"`
+	ErrorClass ErrorClass `json:"errorClass,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// fingerprint identifies the configuration of the file type of a file and
+// the shifts of its dates, so that cached entries are not used once they change
+func fingerprint(cfg *config.Config, path string, shift time.Duration) string {
+	fileType, err := cfg.FileConfig(filepath.Ext(path))
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, dateField := range fileType.DateFields {
+		fmt.Fprintf(&b, "%s\x00%s\x00", dateField.Name, dateField.DateFormat)
+	}
+	if rules := cfg.Shifts(); len(rules) > 0 || shift != 0 {
+		fmt.Fprintf(&b, "%v\x00", shift)
+		for _, rule := range rules {
+			fmt.Fprintf(&b, "%+v\x00", rule.Shift)
+		}
+	}
+	h := fnv.New64a()
+	h.Write([]byte(b.String()))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// cached returns the planned result of a file from the cache, if any
+func cached(fsys filesystem.FS, cache Cache, cfg *config.Config, path string, shift time.Duration, observer Observer) (Result, bool) {
+	if cache == nil {
+		return Result{}, false
+	}
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return Result{}, false
+	}
+	entry, ok := cache.Get(path, info, fingerprint(cfg, path, shift))
+	if !ok {
+		return Result{}, false
+	}
+	res := Result{Path: path, ErrorClass: entry.ErrorClass, Error: entry.Error}
+	if entry.Time == nil {
+		res.Status = StatusFailed
+		return res, true
+	}
+	offset, _ := time.ParseDuration(entry.Shift)
+	observer.DateResolved(path, ResolvedDate{Field: entry.DateField, Raw: entry.RawDate, Time: *entry.Time, Shift: offset, Cached: true})
+	res.DateField = entry.DateField
+	res.RawDate = entry.RawDate
+	res.Time = entry.Time
+	res.Shift = entry.Shift
+	res.NewPath = filepath.Join(filepath.Dir(path), formatFileName(*entry.Time)+filepath.Ext(path))
+	res.Status = StatusPlanned
+	return res, true
+}
+
+// store remembers the date planned for a file, stored under the new path
+// once it is renamed
+func store(fsys filesystem.FS, cache Cache, cfg *config.Config, res Result, shift time.Duration) {
+	if cache == nil || res.ErrorClass == ClassMetadata || res.ErrorClass == ClassRename {
+		return
+	}
+	path := res.Path
+	if res.Status == StatusRenamed {
+		path = res.NewPath
+	}
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return
+	}
+	entry := CacheEntry{ErrorClass: res.ErrorClass, Error: res.Error}
+	if res.Time != nil {
+		entry = CacheEntry{DateField: res.DateField, RawDate: res.RawDate, Time: res.Time, Shift: res.Shift}
+	}
+	cache.Put(path, info, fingerprint(cfg, path, shift), entry)
+}
@@ -0,0 +1,95 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lluissm/media-renamer/internal/filesystem"
+)
+
+// CollisionPolicy decides what happens when the new name of a file is
+// already taken by another file
+type CollisionPolicy string
+
+const (
+	// CollisionSuffix appends _1, _2... to the new name until it is free
+	CollisionSuffix CollisionPolicy = "suffix"
+	// CollisionSkip leaves the file with its name and reports it as failed
+	CollisionSkip CollisionPolicy = "skip"
+	// CollisionOverwrite replaces the other file
+	CollisionOverwrite CollisionPolicy = "overwrite"
+)
+
+// ParseCollisionPolicy returns the policy with the given name
+func ParseCollisionPolicy(name string) (CollisionPolicy, error) {
+	switch p := CollisionPolicy(name); p {
+	case CollisionSuffix, CollisionSkip, CollisionOverwrite:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown collision policy %q", name)
+	}
+}
+
+// resolveCollision applies the policy if the new name of a planned result is
+// taken by another file or was claimed by another file of the run, and
+// claims the resulting name
+func resolveCollision(fsys filesystem.FS, res Result, policy CollisionPolicy, claimed map[string]bool) (Result, error) {
+	taken := func(path string) bool {
+		if path == res.Path {
+			return false
+		}
+		if claimed[path] {
+			return true
+		}
+		_, err := fsys.Lstat(path)
+		return err == nil
+	}
+
+	if taken(res.NewPath) {
+		switch policy {
+		case CollisionOverwrite:
+		case CollisionSkip:
+			err := fmt.Errorf("%w: %s", ErrCollision, res.NewPath)
+			res.fail(err)
+			return res, err
+		default:
+			ext := filepath.Ext(res.NewPath)
+			base := strings.TrimSuffix(res.NewPath, ext)
+			for i := 1; ; i++ {
+				candidate := fmt.Sprintf("%s_%d%s", base, i, ext)
+				if !taken(candidate) {
+					res.NewPath = candidate
+					break
+				}
+			}
+		}
+	}
+	if claimed != nil {
+		claimed[res.NewPath] = true
+	}
+	return res, nil
+}
@@ -0,0 +1,99 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/config"
+	"github.com/lluissm/media-renamer/internal/hooks"
+)
+
+// hookPayload is the input of the rename hooks: the result of the file,
+// planned before the rename and renamed after it
+type hookPayload struct {
+	Hook string `json:"hook"`
+	Result
+}
+
+// runPayload is the input of the postRun hook
+type runPayload struct {
+	Hook    string   `json:"hook"`
+	Results []Result `json:"results"`
+}
+
+// hookVars returns the variables describing a file passed to the rename hooks
+func hookVars(res Result) map[string]string {
+	vars := map[string]string{
+		"OLD_PATH":   res.Path,
+		"NEW_PATH":   res.NewPath,
+		"DATE_FIELD": res.DateField,
+		"RAW_DATE":   res.RawDate,
+		"STATUS":     string(res.Status),
+	}
+	if res.Time != nil {
+		vars["DATE"] = res.Time.Format(time.RFC3339)
+	}
+	return vars
+}
+
+// PostRun runs the postRun hook of cfg, if any, with the results of a run.
+// Nothing is run if there are no results.
+func PostRun(cfg *config.Config, results []Result) error {
+	command := cfg.Hooks().PostRun
+	if command == "" || len(results) == 0 {
+		return nil
+	}
+	renamed, failed := 0, 0
+	for _, res := range results {
+		switch res.Status {
+		case StatusRenamed:
+			renamed++
+		case StatusFailed:
+			failed++
+		}
+	}
+	vars := map[string]string{
+		"TOTAL":   strconv.Itoa(len(results)),
+		"RENAMED": strconv.Itoa(renamed),
+		"FAILED":  strconv.Itoa(failed),
+	}
+	return hooks.Run(hooks.PostRun, command, vars, runPayload{hooks.PostRun, results})
+}
+
+// withPostRun returns the observer of the options and the function running
+// the postRun hook of cfg once the files are processed. The observer also
+// collects the results for the hook, which is not run in a dry run.
+func withPostRun(cfg *config.Config, opts Options) (Observer, func() error) {
+	observer := opts.observer()
+	if cfg.Hooks().PostRun == "" || opts.DryRun {
+		return observer, func() error { return nil }
+	}
+	results := []Result{}
+	collect := ResultFunc(func(res Result) { results = append(results, res) })
+	return Observers{observer, collect}, func() error {
+		return PostRun(cfg, results)
+	}
+}
@@ -0,0 +1,107 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/lluissm/media-renamer/internal/config"
+	"github.com/lluissm/media-renamer/internal/hooks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFolder_Hooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook commands are written for sh")
+	}
+	hooks.Stderr = io.Discard
+	defer func() { hooks.Stderr = os.Stderr }()
+
+	fsys, root := newFS(t, "a.jpeg", "b.jpeg")
+	logs := t.TempDir()
+	renamed := filepath.Join(logs, "renamed")
+	run := filepath.Join(logs, "run")
+	cfg, err := config.Resolve(config.Layer{Source: "test", Bytes: configFile}, config.Layer{Source: "hooks", Bytes: []byte(`
+hooks:
+  preRename: 'case "$MEDIA_RENAMER_OLD_PATH" in *b.jpeg) exit 1;; esac'
+  postRename: 'echo "$MEDIA_RENAMER_NEW_PATH $MEDIA_RENAMER_DATE_FIELD $MEDIA_RENAMER_DATE" >> ` + renamed + `'
+  postRun: 'echo "$MEDIA_RENAMER_TOTAL $MEDIA_RENAMER_RENAMED $MEDIA_RENAMER_FAILED" > ` + run + ` && cat >> ` + run + `'
+`)})
+	assert.NoError(t, err)
+	et := &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+
+	// Nothing is run in a dry run
+	assert.NoError(t, Folder(context.Background(), et, cfg, root, Options{FS: fsys, DryRun: true}))
+	assert.NoFileExists(t, run)
+
+	results := map[string]Result{}
+	opts := Options{FS: fsys, Observer: ResultFunc(func(res Result) { results[filepath.Base(res.Path)] = res })}
+	assert.NoError(t, Folder(context.Background(), et, cfg, root, opts))
+
+	// The preRename hook vetoes the rename of b.jpeg
+	assert.Equal(t, StatusRenamed, results["a.jpeg"].Status)
+	assert.Equal(t, ClassVetoed, results["b.jpeg"].ErrorClass)
+	assert.True(t, exists(fsys, filepath.Join(root, "b.jpeg")))
+
+	written, err := os.ReadFile(renamed)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, expectedFileNameForValidDateJpeg+jpeg)+" CreateDate 2019-08-05T14:12:13Z\n", string(written))
+
+	written, err = os.ReadFile(run)
+	assert.NoError(t, err)
+	lines := strings.SplitN(string(written), "\n", 2)
+	assert.Equal(t, "2 1 1", lines[0])
+	var payload struct {
+		Hook    string   `json:"hook"`
+		Results []Result `json:"results"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &payload))
+	assert.Equal(t, hooks.PostRun, payload.Hook)
+	assert.Len(t, payload.Results, 2)
+}
+
+func TestExecute_PostRenameHookError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook commands are written for sh")
+	}
+	hooks.Stderr = io.Discard
+	defer func() { hooks.Stderr = os.Stderr }()
+
+	fsys, root := newFS(t, "a.jpeg")
+	cfg, err := config.Resolve(config.Layer{Source: "hooks", Bytes: []byte("hooks:\n  postRename: exit 2\n")})
+	assert.NoError(t, err)
+
+	// The file is renamed, the error of the hook is reported in its result
+	res := Execute(Result{Path: filepath.Join(root, "a.jpeg"), NewPath: filepath.Join(root, "b.jpeg"), Status: StatusPlanned}, cfg, Options{FS: fsys})
+	assert.Equal(t, StatusRenamed, res.Status)
+	assert.Contains(t, res.HookError, "exit status 2")
+	assert.True(t, exists(fsys, filepath.Join(root, "b.jpeg")))
+}
@@ -0,0 +1,193 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/config"
+)
+
+type (
+	// Inspection details how the new name of a file is obtained from its metadata
+	Inspection struct {
+		Path string `json:"path"`
+		// Extension and Source identify the configured file type, if any
+		Extension string `json:"extension"`
+		Source    string `json:"source,omitempty"`
+		// Candidates are the configured date fields, in priority order
+		Candidates []Candidate `json:"candidates"`
+		// Winner is the date field used to rename the file, if any
+		Winner  string `json:"winner,omitempty"`
+		NewName string `json:"newName,omitempty"`
+		// Shift is the correction of the date of the winner in NewName
+		Shift string `json:"shift,omitempty"`
+		// Dates are all the date-like tags in the metadata, only listed on request
+		Dates []Tag  `json:"dates,omitempty"`
+		Error string `json:"error,omitempty"`
+	}
+
+	// Candidate is a configured date field and how it parsed for a file
+	Candidate struct {
+		Field      string     `json:"field"`
+		DateFormat string     `json:"dateFormat"`
+		Present    bool       `json:"present"`
+		Raw        string     `json:"raw,omitempty"`
+		Time       *time.Time `json:"time,omitempty"`
+		Error      string     `json:"error,omitempty"`
+		Name       string     `json:"name,omitempty"`
+	}
+
+	// Tag is a metadata tag of a file
+	Tag struct {
+		Name       string `json:"name"`
+		Value      string `json:"value"`
+		Configured bool   `json:"configured"`
+	}
+)
+
+// dateValue matches values that start like a date, e.g., 2019:08:05 or 2019-08-05
+var dateValue = regexp.MustCompile(`^\d{4}[:\-]\d{2}[:\-]\d{2}`)
+
+// Inspect details the date candidates of the supported files in root. A file
+// given as root is inspected even if it is not supported, to tell why. If
+// allDates, the unsupported files found in root are also inspected, to list
+// the date tags of new formats. It stops once ctx is done.
+func Inspect(ctx context.Context, et Extractor, cfg *config.Config, root string, opts Options, allDates bool, fn func(Inspection)) error {
+	if info, err := opts.fs().Stat(root); err == nil && !info.IsDir() {
+		fn(inspectFile(et, cfg, root, opts.Shift, allDates))
+		return nil
+	}
+	observer := opts.observer()
+	if allDates {
+		observer = unsupportedObserver{observer, func(path string) {
+			fn(inspectFile(et, cfg, path, opts.Shift, allDates))
+		}}
+	}
+	return walk(ctx, cfg, root, opts, observer, func(path string) {
+		fn(inspectFile(et, cfg, path, opts.Shift, allDates))
+	})
+}
+
+// unsupportedObserver calls fn with the files skipped for their extension,
+// not the hidden ones
+type unsupportedObserver struct {
+	Observer
+	fn func(path string)
+}
+
+func (o unsupportedObserver) FileSkipped(path string, reason SkipReason) {
+	if reason == SkipUnsupported && !strings.HasPrefix(filepath.Base(path), ".") {
+		o.fn(path)
+		return
+	}
+	o.Observer.FileSkipped(path, reason)
+}
+
+// inspectFile extracts the metadata of a file and evaluates each configured
+// date field, shifting the date of the winner. The metadata of unsupported
+// files is only extracted if allDates, to list their date tags.
+func inspectFile(et Extractor, cfg *config.Config, path string, shift time.Duration, allDates bool) Inspection {
+	ins := Inspection{Path: path, Extension: strings.ToLower(filepath.Ext(path)), Candidates: []Candidate{}}
+	fileType, err := cfg.FileConfig(ins.Extension)
+	if err != nil {
+		ins.Error = fmt.Sprintf("%s %s", ErrUnsupported, ins.Extension)
+		if !allDates {
+			return ins
+		}
+		fileType = &config.FileType{Extension: ins.Extension}
+	} else {
+		ins.Source = cfg.Source(ins.Extension)
+	}
+
+	for _, fileInfo := range et.ExtractMetadata(path) {
+		if fileInfo.Err != nil {
+			if ins.Error == "" {
+				ins.Error = fmt.Sprintf("%s: %v", ErrMetadata, fileInfo.Err)
+			}
+			return ins
+		}
+		ins.Candidates = candidates(fileType, fileInfo.Fields)
+		for _, c := range ins.Candidates {
+			if c.Time != nil {
+				date := &dateMatch{field: c.Field, raw: c.Raw, layout: c.DateFormat, time: *c.Time, name: c.Name}
+				shiftDate(date, shiftOffset(cfg, path, fileInfo, date.time, shift))
+				ins.Winner = c.Field
+				ins.NewName = date.name + filepath.Ext(path)
+				ins.Shift = formatShift(date.shift)
+				break
+			}
+		}
+		if allDates {
+			ins.Dates = dateTags(fileType, fileInfo.Fields)
+		}
+	}
+	return ins
+}
+
+// candidates evaluates the configured date fields of a file type against metadata
+func candidates(fileType *config.FileType, fields map[string]interface{}) []Candidate {
+	res := []Candidate{}
+	for _, dateField := range fileType.DateFields {
+		c := Candidate{Field: dateField.Name, DateFormat: dateField.DateFormat}
+		if value, ok := fields[dateField.Name]; ok {
+			c.Present = true
+			c.Raw = fmt.Sprintf("%v", value)
+			parsed, err := time.Parse(dateField.DateFormat, c.Raw)
+			if err != nil {
+				c.Error = err.Error()
+			} else {
+				c.Time = &parsed
+				c.Name = formatFileName(parsed)
+			}
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+// dateTags returns the tags whose name or value looks like a date, sorted by name
+func dateTags(fileType *config.FileType, fields map[string]interface{}) []Tag {
+	configured := map[string]bool{}
+	for _, dateField := range fileType.DateFields {
+		configured[dateField.Name] = true
+	}
+
+	res := []Tag{}
+	for name, value := range fields {
+		str := fmt.Sprintf("%v", value)
+		if !strings.Contains(name, "Date") && !dateValue.MatchString(str) {
+			continue
+		}
+		res = append(res, Tag{Name: name, Value: str, Configured: configured[name]})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
@@ -0,0 +1,127 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/lluissm/media-renamer/internal/config"
+	"github.com/lluissm/media-renamer/internal/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func inspectAll(t *testing.T, fsys filesystem.FS, et Extractor, root string, allDates bool) []Inspection {
+	res := []Inspection{}
+	assert.NoError(t, Inspect(context.Background(), et, getTestConfig(), root, Options{FS: fsys}, allDates, func(ins Inspection) {
+		res = append(res, ins)
+	}))
+	return res
+}
+
+func TestInspect_Candidates(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg")
+	et := &extractorMock{fields: map[string]interface{}{
+		"RandomKey":         wrongDateValue,
+		validDateKeyForJpeg: validDateValueForJpeg,
+		"ModifyDate":        "2022:11:12 09:10:11",
+		"Make":              "Apple",
+	}}
+
+	inspections := inspectAll(t, fsys, et, root, false)
+	assert.Len(t, inspections, 1)
+	ins := inspections[0]
+	assert.Equal(t, jpeg, ins.Extension)
+	assert.Equal(t, config.DefaultSource, ins.Source)
+	assert.Equal(t, validDateKeyForJpeg, ins.Winner)
+	assert.Equal(t, expectedFileNameForValidDateJpeg+jpeg, ins.NewName)
+	assert.Empty(t, ins.Dates)
+
+	assert.Len(t, ins.Candidates, 2)
+	assert.True(t, ins.Candidates[0].Present)
+	assert.Nil(t, ins.Candidates[0].Time)
+	assert.Contains(t, ins.Candidates[0].Error, "cannot parse")
+	assert.Equal(t, validDateValueForJpeg, ins.Candidates[1].Raw)
+	assert.Equal(t, expectedFileNameForValidDateJpeg, ins.Candidates[1].Name)
+}
+
+func TestInspect_AllDates(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg")
+	et := &extractorMock{fields: map[string]interface{}{
+		validDateKeyForJpeg: validDateValueForJpeg,
+		"ModifyDate":        "2022:11:12 09:10:11",
+		"GPSDateStamp":      "2022:11:12",
+		"Make":              "Apple",
+	}}
+
+	ins := inspectAll(t, fsys, et, root, true)[0]
+	assert.Equal(t, []Tag{
+		{Name: validDateKeyForJpeg, Value: validDateValueForJpeg, Configured: true},
+		{Name: "GPSDateStamp", Value: "2022:11:12"},
+		{Name: "ModifyDate", Value: "2022:11:12 09:10:11"},
+	}, ins.Dates)
+}
+
+func TestInspect_NoDate(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg")
+	ins := inspectAll(t, fsys, &extractorMock{fields: map[string]interface{}{}}, root, false)[0]
+	assert.Equal(t, "", ins.Winner)
+	assert.Equal(t, "", ins.NewName)
+	assert.False(t, ins.Candidates[0].Present)
+	assert.False(t, ins.Candidates[1].Present)
+}
+
+func TestInspect_Errors(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg", "b.txt")
+
+	// A file given explicitly is inspected even if it is not supported
+	ins := inspectAll(t, fsys, &extractorMock{}, filepath.Join(root, "b.txt"), false)
+	assert.Len(t, ins, 1)
+	assert.Contains(t, ins[0].Error, ErrUnsupported.Error())
+
+	// But skipped when walking a folder
+	ins = inspectAll(t, fsys, &extractorMock{}, root, false)
+	assert.Len(t, ins, 1)
+	assert.Contains(t, ins[0].Error, "metadata")
+}
+
+func TestInspect_UnsupportedDates(t *testing.T) {
+	fsys, root := newFS(t, "a.xyz", ".hidden.xyz")
+	et := &extractorMock{fields: map[string]interface{}{"DateTimeOriginal": validDateValueForJpeg, "Make": "Apple"}}
+
+	// The date tags of unsupported files are listed, to configure them
+	for _, path := range []string{filepath.Join(root, "a.xyz"), root} {
+		ins := inspectAll(t, fsys, et, path, true)
+		assert.Len(t, ins, 1, path)
+		assert.Contains(t, ins[0].Error, ErrUnsupported.Error())
+		assert.Empty(t, ins[0].Candidates)
+		assert.Equal(t, []Tag{{Name: "DateTimeOriginal", Value: validDateValueForJpeg}}, ins[0].Dates)
+	}
+
+	// Their metadata is not extracted otherwise
+	et.files = nil
+	assert.Empty(t, inspectAll(t, fsys, et, root, false))
+	assert.Empty(t, et.files)
+}
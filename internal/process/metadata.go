@@ -0,0 +1,115 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/barasher/go-exiftool"
+)
+
+// MetadataWriter writes metadata tags to files, implemented by *exiftool.Exiftool
+type MetadataWriter interface {
+	WriteMetadata(fileMetadata []exiftool.FileMetadata)
+}
+
+const (
+	// preservedFileNameTag keeps the name of a file before its first rename
+	preservedFileNameTag = "XMP-xmpMM:PreservedFileName"
+	// exifDateLayout is the go layout of the EXIF dates
+	exifDateLayout = "2006:01:02 15:04:05"
+	// historyActionTag, historyAgentTag and historyParametersTag record in
+	// the history of a file that its dates were shifted. The trailing + is
+	// exiftool's += that appends an entry to each list, keeping the history
+	// written by other tools.
+	historyActionTag     = "XMP-xmpMM:HistoryAction+"
+	historyAgentTag      = "XMP-xmpMM:HistorySoftwareAgent+"
+	historyParametersTag = "XMP-xmpMM:HistoryParameters+"
+	// shiftedParameters starts the history parameters of a shifted file
+	shiftedParameters = "media-renamer shifted the dates by "
+)
+
+// standardDateFields are the date tags most tools read the capture date from
+var standardDateFields = []string{"DateTimeOriginal", "CreateDate"}
+
+// metadataTags returns the tags to write to a file once it is renamed: its
+// name, unless it was preserved by a previous rename, and the standard date
+// fields missing from its metadata when its date comes from another field.
+// If writeShifted, a shifted date replaces the one of the metadata, keeping
+// its format, fills all the standard date fields and is recorded in the
+// history of the file, so that the shift rules are not applied again.
+func metadataTags(path string, date *dateMatch, fields map[string]interface{}, writeShifted bool) map[string]string {
+	tags := map[string]string{}
+	if _, ok := fields["PreservedFileName"]; !ok {
+		tags[preservedFileNameTag] = filepath.Base(path)
+	}
+	if writeShifted && date.shift != 0 {
+		for _, field := range standardDateFields {
+			tags[field] = date.time.Format(exifDateLayout)
+		}
+		tags[date.field] = date.time.Format(date.layout)
+		tags[historyActionTag] = "edited"
+		tags[historyAgentTag] = "media-renamer"
+		tags[historyParametersTag] = shiftedParameters + date.shift.String()
+		return tags
+	}
+	for _, field := range standardDateFields {
+		if field == date.field {
+			return tags
+		}
+	}
+	for _, field := range standardDateFields {
+		if _, ok := fields[field]; !ok {
+			tags[field] = date.time.Format(exifDateLayout)
+		}
+	}
+	return tags
+}
+
+// shiftedBefore returns true if the dates in the metadata of a file were
+// already shifted and written by a previous run
+func shiftedBefore(fields map[string]interface{}) bool {
+	return strings.Contains(fmt.Sprint(fields["HistoryParameters"]), shiftedParameters)
+}
+
+// writeMetadata writes the metadata tags of a renamed file with w, if set.
+// A failure is reported in the result, the file keeps its new name.
+func writeMetadata(w MetadataWriter, res Result) Result {
+	if w == nil || res.Status != StatusRenamed || len(res.tags) == 0 {
+		return res
+	}
+	md := exiftool.EmptyFileMetadata()
+	md.File = res.NewPath
+	for tag, value := range res.tags {
+		md.SetString(tag, value)
+	}
+	mds := []exiftool.FileMetadata{md}
+	w.WriteMetadata(mds)
+	if err := mds[0].Err; err != nil {
+		res.fail(fmt.Errorf("%w of %s: %v", ErrMetadataWrite, res.NewPath, err))
+	}
+	return res
+}
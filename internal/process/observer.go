@@ -0,0 +1,182 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Observer is notified at each stage of the processing of a file. It is
+// called synchronously from the goroutine processing the files.
+type Observer interface {
+	// FileDiscovered is called when a file passes the filters, right
+	// before it is processed
+	FileDiscovered(path string)
+	// FileSkipped is called for the files and folders that are not processed
+	FileSkipped(path string, reason SkipReason)
+	// DateResolved is called when the date of a file is found, in its
+	// metadata or in the cache
+	DateResolved(path string, date ResolvedDate)
+	// FileRenamed is called with the result of a file named after its
+	// date: renamed, planned in a dry run or already named
+	FileRenamed(res Result)
+	// FileFailed is called with the result of a file that could not be renamed
+	FileFailed(res Result)
+}
+
+// SkipReason tells why a file or folder is not processed
+type SkipReason string
+
+const (
+	// SkipExcluded is a path excluded by the include/exclude patterns or
+	// deeper than the maximum depth
+	SkipExcluded SkipReason = "excluded"
+	// SkipIgnored is a path listed in an ignore file
+	SkipIgnored SkipReason = "ignored"
+	// SkipUnsupported is a hidden file or one with an unsupported extension
+	SkipUnsupported SkipReason = "unsupported"
+	// SkipOutside is a file given to process.Files that is not in its folder
+	SkipOutside SkipReason = "outside"
+)
+
+// ResolvedDate is the date found for a file
+type ResolvedDate struct {
+	Field string
+	Raw   string
+	Time  time.Time
+	// Shift is the correction included in Time, if any
+	Shift time.Duration
+	// Cached is true if the date comes from the cache
+	Cached bool
+}
+
+// BaseObserver ignores every event, to be embedded by observers only
+// interested in some of them
+type BaseObserver struct{}
+
+func (BaseObserver) FileDiscovered(path string)                  {}
+func (BaseObserver) FileSkipped(path string, reason SkipReason)  {}
+func (BaseObserver) DateResolved(path string, date ResolvedDate) {}
+func (BaseObserver) FileRenamed(res Result)                      {}
+func (BaseObserver) FileFailed(res Result)                       {}
+
+// ResultFunc is an Observer calling a function with the result of each
+// processed file, renamed or not
+type ResultFunc func(Result)
+
+func (f ResultFunc) FileDiscovered(path string)                  {}
+func (f ResultFunc) FileSkipped(path string, reason SkipReason)  {}
+func (f ResultFunc) DateResolved(path string, date ResolvedDate) {}
+func (f ResultFunc) FileRenamed(res Result)                      { f(res) }
+func (f ResultFunc) FileFailed(res Result)                       { f(res) }
+
+// Observers notifies several observers, in order. Nil observers are ignored.
+type Observers []Observer
+
+func (o Observers) FileDiscovered(path string) {
+	for _, observer := range o {
+		if observer != nil {
+			observer.FileDiscovered(path)
+		}
+	}
+}
+
+func (o Observers) FileSkipped(path string, reason SkipReason) {
+	for _, observer := range o {
+		if observer != nil {
+			observer.FileSkipped(path, reason)
+		}
+	}
+}
+
+func (o Observers) DateResolved(path string, date ResolvedDate) {
+	for _, observer := range o {
+		if observer != nil {
+			observer.DateResolved(path, date)
+		}
+	}
+}
+
+func (o Observers) FileRenamed(res Result) {
+	for _, observer := range o {
+		if observer != nil {
+			observer.FileRenamed(res)
+		}
+	}
+}
+
+func (o Observers) FileFailed(res Result) {
+	for _, observer := range o {
+		if observer != nil {
+			observer.FileFailed(res)
+		}
+	}
+}
+
+// notify calls the observer method matching the status of a result
+func notify(observer Observer, res Result) {
+	if res.Status == StatusFailed {
+		observer.FileFailed(res)
+	} else {
+		observer.FileRenamed(res)
+	}
+}
+
+// LogObserver logs the events of the processing: renamed files at info
+// level, files that could not be renamed at warn level and the details at
+// debug level
+type LogObserver struct {
+	Logger *slog.Logger
+}
+
+func (o *LogObserver) FileDiscovered(path string) {
+	o.Logger.Debug("Processing file", "path", path)
+}
+
+func (o *LogObserver) FileSkipped(path string, reason SkipReason) {
+	o.Logger.Debug("Skipping", "path", path, "reason", reason)
+}
+
+func (o *LogObserver) DateResolved(path string, date ResolvedDate) {
+	o.Logger.Debug("Date found", "path", path, "field", date.Field, "value", date.Raw, "shift", date.Shift, "cached", date.Cached)
+}
+
+func (o *LogObserver) FileRenamed(res Result) {
+	switch res.Status {
+	case StatusRenamed:
+		o.Logger.Info("Renamed", "path", res.Path, "newPath", res.NewPath)
+		if res.HookError != "" {
+			o.Logger.Warn("The postRename hook failed", "path", res.NewPath, "error", res.HookError)
+		}
+	case StatusAlreadyNamed:
+		o.Logger.Debug("Already named", "path", res.Path)
+	default:
+		o.Logger.Debug("Planned", "path", res.Path, "newPath", res.NewPath)
+	}
+}
+
+func (o *LogObserver) FileFailed(res Result) {
+	o.Logger.Warn("Could not rename file", "path", res.Path, "errorClass", res.ErrorClass, "error", res.Error)
+}
@@ -24,11 +24,13 @@ SOFTWARE.
 package process
 
 import (
+	"context"
 	_ "embed"
+	"errors"
 	"io/fs"
-	"log"
-	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	"fmt"
@@ -36,111 +38,586 @@ import (
 
 	"github.com/barasher/go-exiftool"
 	"github.com/lluissm/media-renamer/internal/config"
+	"github.com/lluissm/media-renamer/internal/filesystem"
+	"github.com/lluissm/media-renamer/internal/filter"
+	"github.com/lluissm/media-renamer/internal/hooks"
 )
 
-type Renamer interface {
-	Rename(oldpath string, newpath string) error
+// Options tune how process.Folder walks and renames files
+type Options struct {
+	// Observer, if set, is notified at each stage of the processing of
+	// every file, e.g., to log, count or report them
+	Observer Observer
+	// Exclude are glob patterns excluded on top of the configured ones
+	Exclude []string
+	// MaxDepth is the maximum depth of the processed files, 1 being the
+	// files directly in the folder. Zero means unlimited.
+	MaxDepth int
+	// FS is the filesystem the files are walked and renamed in,
+	// filesystem.OS if nil
+	FS filesystem.FS
+	// DryRun only computes the new names: files are not renamed and their
+	// results have StatusPlanned, ready to be passed to Execute
+	DryRun bool
+	// TrustNames skips the files whose name already follows the naming
+	// scheme without extracting their metadata
+	TrustNames bool
+	// Cache, if set, is consulted before extracting the metadata of a file
+	// and updated with the date found
+	Cache Cache
+	// Collision decides what to do when the new name of a file is taken,
+	// CollisionSuffix if empty
+	Collision CollisionPolicy
+	// Times selects the timestamps set to the date of the renamed files,
+	// none if empty. They are left untouched in a dry run.
+	Times Times
+	// Metadata writes the original name and the missing standard dates to
+	// the metadata of the renamed files, nothing is written if nil. The
+	// cache is not used then, as it does not keep the metadata of the files.
+	Metadata MetadataWriter
+	// Shift is added to the date of every file, on top of the shift rules
+	// of the configuration
+	Shift time.Duration
+	// WriteShifted writes the shifted dates to the metadata of the renamed
+	// files, see Metadata
+	WriteShifted bool
 }
 
-type osRenamer struct{}
+// observer returns the observer of the options, one ignoring every event if none
+func (o Options) observer() Observer {
+	if o.Observer == nil {
+		return BaseObserver{}
+	}
+	return o.Observer
+}
+
+// cache returns the cache of the options, none when the metadata is written
+func (o Options) cache() Cache {
+	if o.Metadata != nil {
+		return nil
+	}
+	return o.Cache
+}
+
+// fs returns the filesystem of the options, filesystem.OS if none
+func (o Options) fs() filesystem.FS {
+	if o.FS == nil {
+		return filesystem.OS{}
+	}
+	return o.FS
+}
+
+// Extractor extracts the metadata of files, implemented by *exiftool.Exiftool
+type Extractor interface {
+	ExtractMetadata(files ...string) []exiftool.FileMetadata
+}
+
+// process.Folder processes all files in a given path. Once ctx is done it
+// stops after the file being processed, so that no rename is left half
+// done, and returns the context error. The results of the files processed
+// until then have already been reported to opts.Observer.
+func Folder(ctx context.Context, et Extractor, cfg *config.Config, root string, opts Options) error {
+	observer, postRun := withPostRun(cfg, opts)
+	claimed := map[string]bool{}
+	err := walk(ctx, cfg, root, opts, observer, func(path string) {
+		processPath(et, cfg, path, opts, claimed, observer)
+	})
+	return errors.Join(err, postRun())
+}
+
+// Files processes the given files of root, skipping the ones process.Folder
+// would skip when walking root, e.g., because they are excluded or ignored.
+// As process.Folder, it stops once ctx is done.
+func Files(ctx context.Context, et Extractor, cfg *config.Config, root string, paths []string, opts Options) (err error) {
+	observer, postRun := withPostRun(cfg, opts)
+	defer func() {
+		err = errors.Join(err, postRun())
+	}()
+	claimed := map[string]bool{}
+	return filterFiles(ctx, cfg, root, paths, opts, observer, func(path string) {
+		processPath(et, cfg, path, opts, claimed, observer)
+	})
+}
+
+// DiscoverFiles counts the given files of root that process.Files would
+// process, without extracting their metadata
+func DiscoverFiles(ctx context.Context, cfg *config.Config, root string, paths []string, opts Options) (int, error) {
+	count := 0
+	err := filterFiles(ctx, cfg, root, paths, opts, BaseObserver{}, func(path string) {
+		count++
+	})
+	return count, err
+}
+
+// filterFiles calls fn for the given files of root that walk would not
+// skip, until ctx is done
+func filterFiles(ctx context.Context, cfg *config.Config, root string, paths []string, opts Options, observer Observer, fn func(path string)) error {
+	fsys := opts.fs()
+	f, err := filter.New(cfg.Include(), append(cfg.Exclude(), opts.Exclude...))
+	if err != nil {
+		return err
+	}
+	ignore := filter.NewIgnoreReader(fsys.ReadFile)
+	if err := ignore.LoadParents(root); err != nil {
+		return err
+	}
+	loaded := map[string]bool{root: true}
+
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			observer.FileSkipped(path, SkipOutside)
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		// The folders between root and the file must not be skipped either
+		var skipped SkipReason
+		dir := root
+		for _, name := range strings.Split(rel, "/")[:depth(rel)-1] {
+			dir = filepath.Join(dir, name)
+			dirRel, _ := filepath.Rel(root, dir)
+			if skipped = skipDir(filepath.ToSlash(dirRel), dir, f, ignore, opts.MaxDepth); skipped != "" {
+				break
+			}
+			if !loaded[dir] {
+				if err := ignore.Load(dir); err != nil {
+					return err
+				}
+				loaded[dir] = true
+			}
+		}
+
+		d, err := fsys.Lstat(path)
+		if err != nil {
+			skipped = SkipUnsupported
+		} else if skipped == "" {
+			skipped = skipFile(rel, path, fs.FileInfoToDirEntry(d), cfg, f, ignore)
+		}
+		if skipped != "" {
+			observer.FileSkipped(path, skipped)
+			continue
+		}
+		fn(path)
+	}
+	return nil
+}
 
-func (r *osRenamer) Rename(oldpath string, newpath string) error {
-	return os.Rename(oldpath, newpath)
+// processPath processes a file and reports its results
+func processPath(et Extractor, cfg *config.Config, path string, opts Options, claimed map[string]bool, observer Observer) {
+	observer.FileDiscovered(path)
+	var results []Result
+	if res, ok := alreadyNamed(path); ok && opts.TrustNames {
+		if !opts.DryRun {
+			res = setTimes(opts.fs(), res, opts.Times)
+		}
+		results = []Result{res}
+	} else {
+		results = processFile(et, cfg, path, opts, claimed, observer)
+	}
+	for _, res := range results {
+		notify(observer, res)
+	}
 }
 
-// process.Folder processes all files in a given path
-func Folder(et *exiftool.Exiftool, cfg *config.Config, path string, verbose bool) error {
-	err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+// Discover counts the files in a given path that process.Folder would
+// process, without extracting their metadata
+func Discover(ctx context.Context, cfg *config.Config, root string, opts Options) (int, error) {
+	count := 0
+	err := walk(ctx, cfg, root, opts, BaseObserver{}, func(path string) {
+		count++
+	})
+	return count, err
+}
+
+// walk calls fn for every file in root that is not skipped according to
+// the configuration and the options, until ctx is done
+func walk(ctx context.Context, cfg *config.Config, root string, opts Options, observer Observer, fn func(path string)) error {
+	f, err := filter.New(cfg.Include(), append(cfg.Exclude(), opts.Exclude...))
+	if err != nil {
+		return err
+	}
+	fsys := opts.fs()
+	ignore := filter.NewIgnoreReader(fsys.ReadFile)
+	if err := ignore.LoadParents(filepath.Dir(root)); err != nil {
+		return err
+	}
+	// A file given as root is filtered by its name
+	base := root
+	if info, err := fsys.Stat(root); err == nil && !info.IsDir() {
+		base = filepath.Dir(root)
+	}
+
+	return fsys.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if reason := skipDir(rel, path, f, ignore, opts.MaxDepth); reason != "" {
+				observer.FileSkipped(path, reason)
+				return fs.SkipDir
+			}
+			return ignore.Load(path)
+		}
 
-		if shouldIgnoreFile(path, cfg, d) {
+		if reason := skipFile(rel, path, d, cfg, f, ignore); reason != "" {
+			observer.FileSkipped(path, reason)
 			return nil
 		}
 
-		processFile(et, cfg, path, verbose)
+		fn(path)
 		return nil
 	})
+}
 
+// DirSkipper returns a function telling whether walking root skips a
+// folder under it, because it is excluded, ignored or too deep, e.g., so
+// that it is not watched. The ignore files are read on each call, so their
+// changes apply to the folders created later.
+func DirSkipper(cfg *config.Config, root string, opts Options) (func(dir string) bool, error) {
+	f, err := filter.New(cfg.Include(), append(cfg.Exclude(), opts.Exclude...))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	fsys := opts.fs()
+	return func(dir string) bool {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return false
+		}
+		ignore := filter.NewIgnoreReader(fsys.ReadFile)
+		if err := ignore.LoadParents(filepath.Dir(dir)); err != nil {
+			return false
+		}
+		return skipDir(filepath.ToSlash(rel), dir, f, ignore, opts.MaxDepth) != ""
+	}, nil
 }
 
-// processFile tries to rename a file according to its date metadata
-func processFile(et *exiftool.Exiftool, cfg *config.Config, path string, verbose bool) {
+// Roots returns the absolute paths of the given folders and files, sorted
+// and without duplicates or paths contained in another of them, so that no
+// file is processed twice
+func Roots(paths []string) ([]string, error) {
+	abs := []string{}
+	for _, p := range paths {
+		a, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		abs = append(abs, a)
+	}
+	// Sort with the separator before any other character, so that the
+	// contents of a folder come right after it
+	sortKey := func(p string) string {
+		return strings.ReplaceAll(p, string(filepath.Separator), "\x00")
+	}
+	sort.Slice(abs, func(i, j int) bool { return sortKey(abs[i]) < sortKey(abs[j]) })
+
+	roots := []string{}
+	for _, p := range abs {
+		if len(roots) > 0 && isWithin(p, roots[len(roots)-1]) {
+			continue
+		}
+		roots = append(roots, p)
+	}
+	return roots, nil
+}
+
+// Target is a folder to walk or, if Files is set, the files listed in it
+type Target struct {
+	Root  string
+	Files []string
+}
+
+// Targets returns the Roots of paths with the files grouped by folder, so
+// that long lists of files are filtered once per folder. Paths that cannot
+// be read are returned as folders, to report the error when walked.
+func Targets(fsys filesystem.FS, paths []string) ([]Target, error) {
+	roots, err := Roots(paths)
+	if err != nil {
+		return nil, err
+	}
+	targets := []Target{}
+	folders := map[string]int{}
+	for _, root := range roots {
+		if info, err := fsys.Stat(root); err != nil || info.IsDir() {
+			targets = append(targets, Target{Root: root})
+			continue
+		}
+		dir := filepath.Dir(root)
+		i, ok := folders[dir]
+		if !ok {
+			i = len(targets)
+			folders[dir] = i
+			targets = append(targets, Target{Root: dir})
+		}
+		targets[i].Files = append(targets[i].Files, root)
+	}
+	return targets, nil
+}
+
+// isWithin returns true if path is root or is inside it
+func isWithin(path, root string) bool {
+	if path == root {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// processFile tries to rename a file according to its date metadata, or
+// the date cached for it. New names claimed by other files of the run are
+// considered taken.
+func processFile(et Extractor, cfg *config.Config, path string, opts Options, claimed map[string]bool, observer Observer) []Result {
+	fsys := opts.fs()
+	if res, ok := cached(fsys, opts.cache(), cfg, path, opts.Shift, observer); ok {
+		res = finish(fsys, cfg, res, opts, claimed)
+		store(fsys, opts.cache(), cfg, res, opts.Shift)
+		return []Result{res}
+	}
+
 	fileInfos := et.ExtractMetadata(path)
 
+	results := []Result{}
 	for _, fileInfo := range fileInfos {
 		if fileInfo.Err != nil {
-			if verbose {
-				log.Printf("Error concerning %v: %v\n", fileInfo.File, fileInfo.Err)
-			}
+			res := Result{Path: path}
+			res.fail(fmt.Errorf("%w: %v", ErrMetadata, fileInfo.Err))
+			results = append(results, res)
 			continue
 		}
 
-		if err := tryRename(path, cfg, &osRenamer{}, fileInfo, verbose); err != nil {
-			if verbose {
-				log.Printf("Error renaming %s", err.Error())
-			}
-		}
+		res, _ := planRename(path, cfg, fileInfo, opts, observer)
+		res = finish(fsys, cfg, res, opts, claimed)
+		store(fsys, opts.cache(), cfg, res, opts.Shift)
+		results = append(results, res)
 	}
+	return results
 }
 
-// tryGetDate tries to obtain the date from metadata in a format to be used for the file name
-func tryGetDate(path string, fileType *config.FileType, key, value interface{}) (string, error) {
-	for _, dateField := range fileType.DateFields {
-		if key == dateField.Name {
-			dateStr := fmt.Sprintf("%v", value)
-			name, err := newFileName(dateField.DateFormat, dateStr)
-			if err != nil {
-				return "", err
-			}
-			return name, nil
+// finish renames a planned file, with a free name according to the
+// collision policy, and sets its timestamps, unless it is a dry run. Files
+// already named after their date are not renamed.
+func finish(fsys filesystem.FS, cfg *config.Config, res Result, opts Options, claimed map[string]bool) Result {
+	if res.Status == StatusPlanned {
+		res, _ = resolveCollision(fsys, res, opts.Collision, claimed)
+	}
+	if res.Status == StatusPlanned && res.NewPath == res.Path {
+		res.Status = StatusAlreadyNamed
+	}
+	if opts.DryRun {
+		return res
+	}
+	if res.Status == StatusPlanned {
+		res, _ = execute(res, cfg.Hooks(), opts)
+		return res
+	}
+	return setTimes(fsys, res, opts.Times)
+}
+
+// dateMatch is the date found in the metadata of a file
+type dateMatch struct {
+	field string
+	raw   string
+	// layout is the date format the raw date was parsed with
+	layout string
+	time   time.Time
+	name   string
+	// shift is the correction added to the time of the metadata
+	shift time.Duration
+}
+
+// tryGetDate tries to obtain the date from metadata in a format to be used for
+// the file name. Date fields are tried in the order they are configured.
+func tryGetDate(fileType *config.FileType, fields map[string]interface{}) (*dateMatch, error) {
+	found := false
+	for _, c := range candidates(fileType, fields) {
+		if !c.Present {
+			continue
 		}
+		found = true
+		if c.Time == nil {
+			continue
+		}
+		return &dateMatch{
+			field:  c.Field,
+			raw:    c.Raw,
+			layout: c.DateFormat,
+			time:   *c.Time,
+			name:   c.Name,
+		}, nil
+	}
+	if found {
+		return nil, ErrInvalidDate
 	}
-	return "", fmt.Errorf("creation date not found in %v", key)
+	return nil, ErrNoDate
 }
 
-// tryRename tries to rename a file according to its metadata
-func tryRename(path string, cfg *config.Config, renamer Renamer, fileInfo exiftool.FileMetadata, verbose bool) error {
+// planRename computes the new name of a file according to its metadata
+func planRename(path string, cfg *config.Config, fileInfo exiftool.FileMetadata, opts Options, observer Observer) (Result, error) {
+	res := Result{Path: path}
 	ext := filepath.Ext(path)
 	fileConfig, err := cfg.FileConfig(ext)
 	if err != nil {
-		return err
+		err = fmt.Errorf("%w %s", ErrUnsupported, ext)
+		res.fail(err)
+		return res, err
 	}
 
-	for k, v := range fileInfo.Fields {
-		dateStr, err := tryGetDate(path, fileConfig, k, v)
-		if err == nil {
-			dir, _ := filepath.Split(path)
-			ext := filepath.Ext(path)
-			newPath := fmt.Sprintf("%s%s%s", dir, dateStr, ext)
+	date, err := tryGetDate(fileConfig, fileInfo.Fields)
+	if err != nil {
+		err = fmt.Errorf("could not find information in metadata for file %s: %w", path, err)
+		res.fail(err)
+		return res, err
+	}
+	shiftDate(date, shiftOffset(cfg, path, fileInfo, date.time, opts.Shift))
+	res.DateField = date.field
+	res.RawDate = date.raw
+	res.Time = &date.time
+	res.Shift = formatShift(date.shift)
+	res.tags = metadataTags(path, date, fileInfo.Fields, opts.WriteShifted)
+	observer.DateResolved(path, ResolvedDate{Field: date.field, Raw: date.raw, Time: date.time, Shift: date.shift})
 
-			if err = renamer.Rename(path, newPath); err != nil {
-				return fmt.Errorf("Could not rename file %s to %s. %w", path, newPath, err)
-			} else {
-				if verbose {
-					log.Printf("Renamed %s to %s", path, newPath)
-				}
-				return nil
+	dir, _ := filepath.Split(path)
+	res.NewPath = fmt.Sprintf("%s%s%s", dir, date.name, ext)
+	res.Status = StatusPlanned
+	return res, nil
+}
+
+// Execute renames a file planned in a dry run with the filesystem,
+// collision policy, timestamps and observer of the options and the hooks of
+// cfg, and returns its final result. The collision policy is applied again
+// in case the new name was taken since.
+func Execute(res Result, cfg *config.Config, opts Options) Result {
+	fsys := opts.fs()
+	res, err := resolveCollision(fsys, res, opts.Collision, nil)
+	if err == nil {
+		res, _ = execute(res, cfg.Hooks(), opts)
+	}
+	notify(opts.observer(), res)
+	return res
+}
+
+// execute renames a planned file in the filesystem of opts, creating the
+// folder of its new name if needed, writes its metadata, sets its
+// timestamps and runs the rename hooks around it. A failing preRename hook
+// vetoes the rename, a failing postRename hook is only reported in the result.
+func execute(res Result, h config.Hooks, opts Options) (Result, error) {
+	fsys := opts.fs()
+	if h.PreRename != "" {
+		if err := hooks.Run(hooks.PreRename, h.PreRename, hookVars(res), hookPayload{hooks.PreRename, res}); err != nil {
+			err = fmt.Errorf("%w: %v", ErrVetoed, err)
+			res.fail(err)
+			return res, err
+		}
+	}
+	if err := rename(fsys, res.Path, res.NewPath); err != nil {
+		err = fmt.Errorf("%w %s to %s: %v", ErrRename, res.Path, res.NewPath, err)
+		res.fail(err)
+		return res, err
+	}
+	res.Status = StatusRenamed
+	// The metadata is written first, as it changes the modification time
+	res = writeMetadata(opts.Metadata, res)
+	res = setTimes(fsys, res, opts.Times)
+	if h.PostRename != "" {
+		if err := hooks.Run(hooks.PostRename, h.PostRename, hookVars(res), hookPayload{hooks.PostRename, res}); err != nil {
+			res.HookError = err.Error()
+		}
+	}
+	return res, nil
+}
+
+// rename renames a file, creating the folder of newpath if it is missing
+func rename(fsys filesystem.FS, oldpath, newpath string) error {
+	if dir := filepath.Dir(newpath); dir != filepath.Dir(oldpath) {
+		if _, err := fsys.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			if err := fsys.MkdirAll(dir, 0755); err != nil {
+				return err
 			}
 		}
 	}
-	return fmt.Errorf("could not find information in metadata for file %s", path)
+	return fsys.Rename(oldpath, newpath)
 }
 
-// newFileName returns the date formated to be used as a file name
-func newFileName(dateFormat, date string) (string, error) {
-	parseTime, err := time.Parse(dateFormat, date)
+// fileNameLayout is the go layout of the file names, see formatFileName
+const fileNameLayout = "2006_01_02_15_04_05"
 
-	if err != nil {
-		return "", err
+// collisionSuffix matches the suffix added to a file name taken by another file
+var collisionSuffix = regexp.MustCompile(`_\d+$`)
+
+// formatFileName returns the file name (without extension) for a date
+func formatFileName(t time.Time) string {
+	return fmt.Sprintf("%04d_%02d_%02d_%02d_%02d_%02d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
+}
+
+// alreadyNamed returns the result of a file whose name follows the naming
+// scheme, with or without a collision suffix, trusting that the date in the
+// name matches its metadata
+func alreadyNamed(path string) (Result, bool) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if len(name) > len(fileNameLayout) {
+		name = collisionSuffix.ReplaceAllString(name, "")
+	}
+	t, err := time.Parse(fileNameLayout, name)
+	if err != nil || formatFileName(t) != name {
+		return Result{}, false
+	}
+	return Result{Path: path, NewPath: path, Status: StatusAlreadyNamed, Time: &t}, true
+}
+
+// shouldSkipDir returns true if the folder is excluded or walking it would
+// exceed the maximum depth. The processed folder itself is never skipped.
+func shouldSkipDir(rel string, f *filter.Filter, maxDepth int) bool {
+	if rel == "." {
+		return false
 	}
+	if maxDepth > 0 && depth(rel) >= maxDepth {
+		return true
+	}
+	return f.Excluded(rel)
+}
+
+// depth returns the number of path elements of a relative slash separated path
+func depth(rel string) int {
+	return strings.Count(rel, "/") + 1
+}
 
-	return fmt.Sprintf("%04d_%02d_%02d_%02d_%02d_%02d", parseTime.Year(), parseTime.Month(), parseTime.Day(), parseTime.Hour(), parseTime.Minute(), parseTime.Second()), nil
+// skipDir returns why a folder is skipped, if it is
+func skipDir(rel, path string, f *filter.Filter, ignore *filter.Ignore, maxDepth int) SkipReason {
+	switch {
+	case shouldSkipDir(rel, f, maxDepth):
+		return SkipExcluded
+	case ignore.Ignored(path, true):
+		return SkipIgnored
+	default:
+		return ""
+	}
+}
+
+// skipFile returns why a file is skipped, if it is
+func skipFile(rel, path string, d fs.DirEntry, cfg *config.Config, f *filter.Filter, ignore *filter.Ignore) SkipReason {
+	switch {
+	case f.Excluded(rel) || !f.Included(rel):
+		return SkipExcluded
+	case ignore.Ignored(path, false):
+		return SkipIgnored
+	case shouldIgnoreFile(path, cfg, d):
+		return SkipUnsupported
+	default:
+		return ""
+	}
 }
 
 // shouldIgnoreFile returns true if the file is a folder, its extension is
@@ -24,14 +24,26 @@ SOFTWARE.
 package process
 
 import (
-	_ "embed"
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"testing"
+	"time"
 
 	"github.com/barasher/go-exiftool"
 	"github.com/lluissm/media-renamer/internal/config"
+	"github.com/lluissm/media-renamer/internal/filesystem"
+	"github.com/lluissm/media-renamer/internal/filter"
+	"github.com/lluissm/media-renamer/internal/logging"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -39,6 +51,9 @@ import (
 //go:embed testdata/config.yml
 var configFile []byte
 
+//go:embed testdata/metadata/*.json
+var metadataFixtures embed.FS
+
 // File names
 const jpeg = ".jpeg"
 const validImagePath = "IMG_0001.jpeg"
@@ -48,17 +63,14 @@ const hiddenImagePath = ".hidden.jpeg"
 // JPEG
 const validDateKeyForJpeg = "CreateDate"
 const validDateValueForJpeg = "2019:08:05 14:12:13"
-const validDateFormatJpeg = "2006:01:02 15:04:05"
 const wrongDateKeyForJpeg = "unknown"
 const expectedFileNameForValidDateJpeg = "2019_08_05_14_12_13"
 
 // MOV
 const validDateFormatMOV = "2006:01:02 15:04:05-07:00"
 const validDateMOV = "2015:07:15 13:56:17+02:00"
-const expectedFileNameForValidDateMov = "2015_07_15_13_56_17"
 
 // Wrong dates
-const wrongDateFormat = "2022"
 const wrongDateValue = "wrong date"
 
 func getTestConfig() *config.Config {
@@ -69,6 +81,735 @@ func getTestConfig() *config.Config {
 	return nil
 }
 
+///////////////////////////////////
+//			Folder
+///////////////////////////////////
+
+// extractorMock records the files whose metadata is extracted and returns
+// the same fields for all of them, or an error if there are none
+type extractorMock struct {
+	files  []string
+	fields map[string]interface{}
+}
+
+func (e *extractorMock) ExtractMetadata(files ...string) []exiftool.FileMetadata {
+	res := []exiftool.FileMetadata{}
+	for _, f := range files {
+		e.files = append(e.files, f)
+		if e.fields == nil {
+			res = append(res, exiftool.FileMetadata{File: f, Err: errors.New("no metadata")})
+			continue
+		}
+		res = append(res, exiftool.FileMetadata{File: f, Fields: e.fields})
+	}
+	return res
+}
+
+// newFS returns an in-memory filesystem with empty files (and their
+// folders) inside the returned root
+func newFS(t *testing.T, files ...string) (*filesystem.Memory, string) {
+	root, err := filepath.Abs(filepath.FromSlash("/photos"))
+	assert.NoError(t, err)
+	fsys := filesystem.NewMemory()
+	assert.NoError(t, fsys.MkdirAll(root, 0755))
+	for _, f := range files {
+		assert.NoError(t, fsys.WriteFile(filepath.Join(root, filepath.FromSlash(f)), nil, 0644))
+	}
+	return fsys, root
+}
+
+// exists returns true if path exists in fsys
+func exists(fsys filesystem.FS, path string) bool {
+	_, err := fsys.Lstat(path)
+	return err == nil
+}
+
+// processedFiles runs Folder in fsys and returns the files sent to the
+// extractor relative to root
+func processedFiles(t *testing.T, fsys filesystem.FS, cfg *config.Config, root string, opts Options) []string {
+	et := &extractorMock{}
+	opts.FS = fsys
+	assert.NoError(t, Folder(context.Background(), et, cfg, root, opts))
+
+	res := []string{}
+	for _, f := range et.files {
+		rel, err := filepath.Rel(root, f)
+		assert.NoError(t, err)
+		res = append(res, filepath.ToSlash(rel))
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestFolder_Filters(t *testing.T) {
+	fsys, root := newFS(t,
+		"a.jpeg",
+		"b.txt",
+		".hidden.jpeg",
+		"2021/c.mov",
+		"2021/.thumbnails/c.jpeg",
+		"2021/summer/d.jpeg",
+		"@eaDir/e.jpeg",
+	)
+	cfg := getTestConfig()
+
+	// Everything supported
+	files := processedFiles(t, fsys, cfg, root, Options{})
+	assert.Equal(t, []string{"2021/.thumbnails/c.jpeg", "2021/c.mov", "2021/summer/d.jpeg", "@eaDir/e.jpeg", "a.jpeg"}, files)
+
+	// Excluded folders are pruned
+	files = processedFiles(t, fsys, cfg, root, Options{Exclude: []string{"**/.thumbnails", "@eaDir", "2021/summer/**"}})
+	assert.Equal(t, []string{"2021/c.mov", "a.jpeg"}, files)
+
+	// Depth limit
+	files = processedFiles(t, fsys, cfg, root, Options{MaxDepth: 1})
+	assert.Equal(t, []string{"a.jpeg"}, files)
+	files = processedFiles(t, fsys, cfg, root, Options{MaxDepth: 2})
+	assert.Equal(t, []string{"2021/c.mov", "@eaDir/e.jpeg", "a.jpeg"}, files)
+
+	// Include patterns from config
+	cfg, err := config.Resolve(
+		config.Layer{Source: "test", Bytes: configFile},
+		config.Layer{Source: "include", Bytes: []byte("include: [\"*.mov\"]")},
+	)
+	assert.NoError(t, err)
+	files = processedFiles(t, fsys, cfg, root, Options{})
+	assert.Equal(t, []string{"2021/c.mov"}, files)
+}
+
+func TestFolder_IgnoreFiles(t *testing.T) {
+	fsys, root := newFS(t,
+		"a.jpeg",
+		"b.jpeg",
+		"curated/c.jpeg",
+		"2021/d.mov",
+		"2021/e.mov",
+	)
+	cfg := getTestConfig()
+	assert.NoError(t, fsys.WriteFile(filepath.Join(root, ".media-renamer-ignore"), []byte("b.jpeg\ncurated/\n*.mov\n"), 0644))
+	assert.NoError(t, fsys.WriteFile(filepath.Join(root, "2021", ".media-renamer-ignore"), []byte("!e.mov\n"), 0644))
+
+	files := processedFiles(t, fsys, cfg, root, Options{})
+	assert.Equal(t, []string{"2021/e.mov", "a.jpeg"}, files)
+
+	// Ignore files of parent folders also apply
+	files = processedFiles(t, fsys, cfg, filepath.Join(root, "curated"), Options{})
+	assert.Empty(t, files)
+}
+
+func TestFolder_ResultFunc(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg")
+
+	results := []Result{}
+	opts := Options{FS: fsys, Observer: ResultFunc(func(res Result) { results = append(results, res) })}
+	assert.NoError(t, Folder(context.Background(), &extractorMock{}, getTestConfig(), root, opts))
+
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, filepath.Join(root, "a.jpeg"), results[0].Path)
+	assert.Equal(t, StatusFailed, results[0].Status)
+	assert.Equal(t, ClassMetadata, results[0].ErrorClass)
+}
+
+func TestFolder_DryRunAndExecute(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg")
+	et := &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+
+	results := []Result{}
+	opts := Options{FS: fsys, DryRun: true, Observer: ResultFunc(func(res Result) { results = append(results, res) })}
+	assert.NoError(t, Folder(context.Background(), et, getTestConfig(), root, opts))
+
+	// Nothing is renamed in a dry run
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, StatusPlanned, results[0].Status)
+	assert.Equal(t, filepath.Join(root, expectedFileNameForValidDateJpeg+jpeg), results[0].NewPath)
+	assert.True(t, exists(fsys, filepath.Join(root, "a.jpeg")))
+
+	// Executing the plan renames the file
+	res := Execute(results[0], getTestConfig(), Options{FS: fsys})
+	assert.Equal(t, StatusRenamed, res.Status)
+	assert.False(t, exists(fsys, filepath.Join(root, "a.jpeg")))
+	assert.True(t, exists(fsys, res.NewPath))
+
+	// Executing it again fails as the file is gone
+	res = Execute(results[0], getTestConfig(), Options{FS: fsys})
+	assert.Equal(t, StatusFailed, res.Status)
+	assert.Equal(t, ClassRename, res.ErrorClass)
+}
+
+func TestExecute_CreatesFolder(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg")
+	newPath := filepath.Join(root, "2019", "08", "b.jpeg")
+
+	// The missing folders of the new name are created
+	res := Execute(Result{Path: filepath.Join(root, "a.jpeg"), NewPath: newPath, Status: StatusPlanned}, getTestConfig(), Options{FS: fsys})
+	assert.Equal(t, StatusRenamed, res.Status)
+	assert.True(t, exists(fsys, newPath))
+	assert.False(t, exists(fsys, filepath.Join(root, "a.jpeg")))
+}
+
+func TestFolder_Times(t *testing.T) {
+	named := expectedFileNameForValidDateJpeg + jpeg
+	et := &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+	date := time.Date(2019, 8, 5, 14, 12, 13, 0, time.Local)
+	mtime := func(fsys filesystem.FS, path string) time.Time {
+		info, err := fsys.Stat(path)
+		assert.NoError(t, err)
+		return info.ModTime()
+	}
+
+	// Nothing is touched in a dry run
+	fsys, root := newFS(t, "a.jpeg")
+	times := Times{Mtime: true, Unchanged: true}
+	assert.NoError(t, Folder(context.Background(), et, getTestConfig(), root, Options{FS: fsys, DryRun: true, Times: times}))
+	assert.False(t, date.Equal(mtime(fsys, filepath.Join(root, "a.jpeg"))))
+
+	// The renamed files get their date, the already named ones only if asked
+	fsys, root = newFS(t, named, "b/a.jpeg")
+	assert.NoError(t, Folder(context.Background(), et, getTestConfig(), root, Options{FS: fsys, Times: Times{Mtime: true}}))
+	assert.True(t, date.Equal(mtime(fsys, filepath.Join(root, "b", named))))
+	assert.False(t, date.Equal(mtime(fsys, filepath.Join(root, named))))
+
+	for _, trustNames := range []bool{false, true} {
+		fsys, root = newFS(t, named)
+		assert.NoError(t, Folder(context.Background(), et, getTestConfig(), root, Options{FS: fsys, TrustNames: trustNames, Times: times}))
+		assert.True(t, date.Equal(mtime(fsys, filepath.Join(root, named))))
+	}
+
+	// Executing a plan sets them too
+	fsys, root = newFS(t, "a.jpeg")
+	res := Execute(Result{Path: filepath.Join(root, "a.jpeg"), NewPath: filepath.Join(root, named), Status: StatusPlanned, Time: &date}, getTestConfig(), Options{FS: fsys, Times: times})
+	assert.Equal(t, StatusRenamed, res.Status)
+	assert.True(t, date.Equal(mtime(fsys, filepath.Join(root, named))))
+
+	// Failing to set them fails the file
+	res = setTimes(fsys, Result{Path: filepath.Join(root, "a.jpeg"), NewPath: filepath.Join(root, "a.jpeg"), Status: StatusRenamed, Time: &date}, times)
+	assert.Equal(t, ClassTimes, res.ErrorClass)
+	assert.ErrorIs(t, res.Err(), ErrTimes)
+}
+
+// metadataWriterMock records the tags written to each file, failing for
+// the files in fail
+type metadataWriterMock struct {
+	written map[string]map[string]interface{}
+	fail    map[string]bool
+}
+
+func (w *metadataWriterMock) WriteMetadata(fileMetadata []exiftool.FileMetadata) {
+	for i, md := range fileMetadata {
+		if w.fail[filepath.Base(md.File)] {
+			fileMetadata[i].Err = errors.New("read-only file")
+			continue
+		}
+		w.written[filepath.Base(md.File)] = md.Fields
+	}
+}
+
+func TestFolder_Metadata(t *testing.T) {
+	named := expectedFileNameForValidDateJpeg + jpeg
+	fsys, root := newFS(t, "a.jpeg")
+	writer := &metadataWriterMock{written: map[string]map[string]interface{}{}}
+	cache := &cacheMock{entries: map[string]CacheEntry{}}
+	run := func(et Extractor, dryRun bool) []Result {
+		results := []Result{}
+		opts := Options{FS: fsys, DryRun: dryRun, Cache: cache, Metadata: writer, Observer: ResultFunc(func(res Result) { results = append(results, res) })}
+		assert.NoError(t, Folder(context.Background(), et, getTestConfig(), root, opts))
+		return results
+	}
+
+	// Nothing is written in a dry run
+	et := &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+	run(et, true)
+	assert.Empty(t, writer.written)
+
+	// The original name is preserved, the date comes from a standard field
+	results := run(et, false)
+	assert.Equal(t, StatusRenamed, results[0].Status)
+	assert.Equal(t, map[string]interface{}{preservedFileNameTag: "a.jpeg"}, writer.written[named])
+	assert.Empty(t, cache.entries)
+
+	// Dates from other fields fill the missing standard ones, names
+	// preserved by a previous rename are kept
+	writer.written = map[string]map[string]interface{}{}
+	assert.NoError(t, fsys.Rename(filepath.Join(root, named), filepath.Join(root, "b.jpeg")))
+	et = &extractorMock{fields: map[string]interface{}{
+		"RandomKey":         validDateValueForJpeg,
+		"CreateDate":        "2019:08:05 14:12:00",
+		"PreservedFileName": "a.jpeg",
+	}}
+	run(et, false)
+	assert.Equal(t, map[string]interface{}{"DateTimeOriginal": validDateValueForJpeg}, writer.written[named])
+
+	// Failing to write it fails the file, which keeps its new name
+	fsys, root = newFS(t, "a.jpeg")
+	writer.fail = map[string]bool{named: true}
+	et = &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+	results = run(et, false)
+	assert.Equal(t, ClassMetadataWrite, results[0].ErrorClass)
+	assert.ErrorIs(t, results[0].Err(), ErrMetadataWrite)
+	assert.True(t, exists(fsys, filepath.Join(root, named)))
+}
+
+func TestFolder_Shift(t *testing.T) {
+	cfg, err := config.Resolve(config.Layer{Source: "test", Bytes: configFile}, config.Layer{Source: "shifts", Bytes: []byte(`
+shifts:
+  - make: Canon
+    offset: 1h
+  - folder: trip
+    offset: -30m
+`)})
+	assert.NoError(t, err)
+	fsys, root := newFS(t, "a.jpeg", "trip/b.jpeg")
+	fields := map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg, "Make": "Canon"}
+	plan := func(opts Options) map[string]Result {
+		results := map[string]Result{}
+		opts.FS = fsys
+		opts.DryRun = true
+		opts.Observer = ResultFunc(func(res Result) { results[filepath.Base(res.Path)] = res })
+		assert.NoError(t, Folder(context.Background(), &extractorMock{fields: fields}, cfg, root, opts))
+		return results
+	}
+
+	// The last matching rule wins, the shift of the options is added
+	results := plan(Options{})
+	assert.Equal(t, filepath.Join(root, "2019_08_05_15_12_13.jpeg"), results["a.jpeg"].NewPath)
+	assert.Equal(t, "1h0m0s", results["a.jpeg"].Shift)
+	assert.Equal(t, validDateValueForJpeg, results["a.jpeg"].RawDate)
+	assert.Equal(t, filepath.Join(root, "trip", "2019_08_05_13_42_13.jpeg"), results["b.jpeg"].NewPath)
+	results = plan(Options{Shift: -time.Hour})
+	assert.Equal(t, filepath.Join(root, expectedFileNameForValidDateJpeg+jpeg), results["a.jpeg"].NewPath)
+	assert.Empty(t, results["a.jpeg"].Shift)
+
+	// The shifted date is written back on request, keeping the time zone
+	// of its field, and recorded in the history of the file
+	zone := time.FixedZone("", 2*60*60)
+	date := &dateMatch{field: "CreationDate", layout: validDateFormatMOV, time: time.Date(2015, 7, 15, 13, 56, 17, 0, zone), shift: time.Hour}
+	tags := metadataTags("a.mov", date, fields, true)
+	assert.Equal(t, "2015:07:15 13:56:17", tags["DateTimeOriginal"])
+	assert.Equal(t, validDateMOV, tags["CreationDate"])
+	assert.Equal(t, shiftedParameters+"1h0m0s", tags[historyParametersTag])
+	assert.NotContains(t, metadataTags("a.mov", date, fields, false), "CreationDate")
+
+	// Cached dates are only used with the same shifts
+	assert.NotEqual(t, fingerprint(cfg, "a.jpeg", 0), fingerprint(cfg, "a.jpeg", time.Hour))
+	assert.NotEqual(t, fingerprint(getTestConfig(), "a.jpeg", 0), fingerprint(cfg, "a.jpeg", 0))
+}
+
+// fileMock is the metadata of a single file, updated by the tags written to it
+type fileMock map[string]interface{}
+
+func (f fileMock) ExtractMetadata(files ...string) []exiftool.FileMetadata {
+	return []exiftool.FileMetadata{{File: files[0], Fields: f}}
+}
+
+func (f fileMock) WriteMetadata(fileMetadata []exiftool.FileMetadata) {
+	for tag, value := range fileMetadata[0].Fields {
+		name := strings.TrimSuffix(tag[strings.Index(tag, ":")+1:], "+")
+		if !strings.HasSuffix(tag, "+") {
+			f[name] = value
+			continue
+		}
+		// += appends to the list
+		list, _ := f[name].([]interface{})
+		if value, ok := f[name].(string); ok {
+			list = []interface{}{value}
+		}
+		f[name] = append(list, value)
+	}
+}
+
+func TestFolder_ShiftWrittenOnce(t *testing.T) {
+	cfg, err := config.Resolve(config.Layer{Source: "test", Bytes: configFile}, config.Layer{Source: "shifts", Bytes: []byte("shifts:\n  - make: Canon\n    offset: 1h\n")})
+	assert.NoError(t, err)
+	fsys, root := newFS(t, "a.jpeg")
+	file := fileMock{
+		validDateKeyForJpeg: validDateValueForJpeg,
+		"Make":              "Canon",
+		"HistoryAction":     "saved",
+		"HistoryParameters": "converted from raw",
+	}
+	run := func() Result {
+		var res Result
+		opts := Options{FS: fsys, Metadata: file, WriteShifted: true, Shift: time.Minute, Observer: ResultFunc(func(r Result) { res = r })}
+		assert.NoError(t, Folder(context.Background(), file, cfg, root, opts))
+		return res
+	}
+
+	// The second run finds the shifted date and does not shift it again
+	res := run()
+	assert.Equal(t, StatusRenamed, res.Status)
+	assert.Equal(t, filepath.Join(root, "2019_08_05_15_13_13.jpeg"), res.NewPath)
+	assert.Equal(t, "2019:08:05 15:13:13", file[validDateKeyForJpeg])
+	res = run()
+	assert.Equal(t, StatusAlreadyNamed, res.Status)
+	assert.Empty(t, res.Shift)
+	assert.True(t, exists(fsys, filepath.Join(root, "2019_08_05_15_13_13.jpeg")))
+
+	// The shift is added to the history of the file, keeping its entries
+	assert.Equal(t, []interface{}{"saved", "edited"}, file["HistoryAction"])
+	assert.Equal(t, []interface{}{"converted from raw", shiftedParameters + "1h1m0s"}, file["HistoryParameters"])
+}
+
+func TestFolder_Renamer(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg")
+	et := &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+	renamer := renamerMock{FS: fsys}
+
+	renamer.On("Rename", filepath.Join(root, "a.jpeg"), filepath.Join(root, expectedFileNameForValidDateJpeg+jpeg)).Return(nil).Once()
+	assert.NoError(t, Folder(context.Background(), et, getTestConfig(), root, Options{FS: &renamer}))
+	renamer.AssertExpectations(t)
+}
+
+func TestFolder_AlreadyNamed(t *testing.T) {
+	named := expectedFileNameForValidDateJpeg + jpeg
+	fsys, root := newFS(t, named, "2001_02_03_04_05_06.jpeg", "2019_13_05_14_12_13.jpeg")
+	et := &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+	renamer := renamerMock{FS: fsys}
+
+	results := map[string]Result{}
+	opts := Options{FS: &renamer, DryRun: true, Observer: ResultFunc(func(res Result) { results[filepath.Base(res.Path)] = res })}
+	assert.NoError(t, Folder(context.Background(), et, getTestConfig(), root, opts))
+
+	// The file named after the date in its metadata is not renamed, the
+	// ones whose name does not match the metadata are
+	assert.Len(t, et.files, 3)
+	assert.Equal(t, StatusAlreadyNamed, results[named].Status)
+	assert.Equal(t, filepath.Join(root, named), results[named].NewPath)
+	assert.Equal(t, StatusPlanned, results["2001_02_03_04_05_06.jpeg"].Status)
+	assert.Equal(t, StatusPlanned, results["2019_13_05_14_12_13.jpeg"].Status)
+	renamer.AssertNotCalled(t, "Rename", mock.Anything, mock.Anything)
+}
+
+func TestFolder_Collision(t *testing.T) {
+	named := expectedFileNameForValidDateJpeg + jpeg
+	et := &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+
+	plan := func(collision CollisionPolicy) (*filesystem.Memory, string, map[string]Result) {
+		fsys, root := newFS(t, named, "a.jpeg", "b.jpeg")
+		results := map[string]Result{}
+		opts := Options{FS: fsys, DryRun: true, Collision: collision, Observer: ResultFunc(func(res Result) { results[filepath.Base(res.Path)] = res })}
+		assert.NoError(t, Folder(context.Background(), et, getTestConfig(), root, opts))
+		return fsys, root, results
+	}
+
+	// Burst shots taken in the same second get a suffix, also when their
+	// names are only planned
+	_, root, results := plan(CollisionSuffix)
+	assert.Equal(t, StatusAlreadyNamed, results[named].Status)
+	assert.Equal(t, filepath.Join(root, expectedFileNameForValidDateJpeg+"_1"+jpeg), results["a.jpeg"].NewPath)
+	assert.Equal(t, filepath.Join(root, expectedFileNameForValidDateJpeg+"_2"+jpeg), results["b.jpeg"].NewPath)
+
+	_, _, results = plan(CollisionSkip)
+	assert.Equal(t, StatusFailed, results["a.jpeg"].Status)
+	assert.Equal(t, ClassCollision, results["a.jpeg"].ErrorClass)
+	assert.ErrorIs(t, results["a.jpeg"].Err(), ErrCollision)
+	assert.NoError(t, results[named].Err())
+
+	fsys, root, results := plan(CollisionOverwrite)
+	assert.Equal(t, filepath.Join(root, named), results["a.jpeg"].NewPath)
+
+	// Executing a plan whose name was taken since applies the policy again
+	res := Execute(results["a.jpeg"], getTestConfig(), Options{FS: fsys, Collision: CollisionSkip})
+	assert.Equal(t, ClassCollision, res.ErrorClass)
+	assert.True(t, exists(fsys, filepath.Join(root, "a.jpeg")))
+	res = Execute(results["a.jpeg"], getTestConfig(), Options{FS: fsys})
+	assert.Equal(t, StatusRenamed, res.Status)
+	assert.Equal(t, filepath.Join(root, expectedFileNameForValidDateJpeg+"_1"+jpeg), res.NewPath)
+
+	// Suffixed names follow the naming scheme
+	_, ok := alreadyNamed(res.NewPath)
+	assert.True(t, ok)
+	_, ok = alreadyNamed("2019_08_05_14_12_1.jpeg")
+	assert.False(t, ok)
+}
+
+func TestParseCollisionPolicy(t *testing.T) {
+	policy, err := ParseCollisionPolicy("skip")
+	assert.NoError(t, err)
+	assert.Equal(t, CollisionSkip, policy)
+	_, err = ParseCollisionPolicy("rename")
+	assert.Error(t, err)
+}
+
+// observerMock records the events of the processing as strings
+type observerMock struct {
+	events []string
+}
+
+func (o *observerMock) FileDiscovered(path string) {
+	o.events = append(o.events, "discovered "+filepath.Base(path))
+}
+
+func (o *observerMock) FileSkipped(path string, reason SkipReason) {
+	o.events = append(o.events, fmt.Sprintf("skipped %s %s", filepath.Base(path), reason))
+}
+
+func (o *observerMock) DateResolved(path string, date ResolvedDate) {
+	o.events = append(o.events, fmt.Sprintf("date %s %s %t", filepath.Base(path), date.Field, date.Cached))
+}
+
+func (o *observerMock) FileRenamed(res Result) {
+	o.events = append(o.events, fmt.Sprintf("renamed %s %s", filepath.Base(res.Path), res.Status))
+}
+
+func (o *observerMock) FileFailed(res Result) {
+	o.events = append(o.events, fmt.Sprintf("failed %s %s", filepath.Base(res.Path), res.ErrorClass))
+}
+
+func TestFolder_Observer(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg", "b.txt", "c.mov", "skip/d.jpeg")
+	et := &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+
+	observer := &observerMock{}
+	opts := Options{FS: fsys, DryRun: true, Exclude: []string{"skip"}, Observer: observer}
+	assert.NoError(t, Folder(context.Background(), et, getTestConfig(), root, opts))
+	assert.Equal(t, []string{
+		"discovered a.jpeg",
+		"date a.jpeg CreateDate false",
+		"renamed a.jpeg planned",
+		"skipped b.txt unsupported",
+		"discovered c.mov",
+		"failed c.mov no-date",
+		"skipped skip excluded",
+	}, observer.events)
+
+	// Executing a planned rename notifies the observer too
+	observer.events = nil
+	res := Execute(Result{Path: filepath.Join(root, "a.jpeg"), NewPath: filepath.Join(root, "e.jpeg"), Status: StatusPlanned}, getTestConfig(), Options{FS: fsys, Observer: observer})
+	assert.Equal(t, StatusRenamed, res.Status)
+	assert.Equal(t, []string{"renamed a.jpeg renamed"}, observer.events)
+}
+
+func TestFolder_TrustNames(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg", "2001_02_03_04_05_06.jpeg", "2019_13_05_14_12_13.jpeg")
+	et := &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+
+	results := map[string]Result{}
+	progress := &Progress{}
+	opts := Options{FS: fsys, TrustNames: true, DryRun: true, Observer: Observers{progress, ResultFunc(func(res Result) { results[filepath.Base(res.Path)] = res })}}
+	assert.NoError(t, Folder(context.Background(), et, getTestConfig(), root, opts))
+
+	// Files named after a valid date are skipped without extracting their metadata
+	assert.ElementsMatch(t, []string{filepath.Join(root, "a.jpeg"), filepath.Join(root, "2019_13_05_14_12_13.jpeg")}, et.files)
+	res := results["2001_02_03_04_05_06.jpeg"]
+	assert.Equal(t, StatusAlreadyNamed, res.Status)
+	assert.Equal(t, 2001, res.Time.Year())
+	assert.Equal(t, int64(1), progress.AlreadyNamed.Load())
+}
+
+// cacheMock is an in memory Cache keyed by path and fingerprint
+type cacheMock struct {
+	entries map[string]CacheEntry
+}
+
+func (c *cacheMock) Get(path string, info fs.FileInfo, fingerprint string) (CacheEntry, bool) {
+	entry, ok := c.entries[path+fingerprint]
+	return entry, ok
+}
+
+func (c *cacheMock) Put(path string, info fs.FileInfo, fingerprint string, entry CacheEntry) {
+	c.entries[path+fingerprint] = entry
+}
+
+func TestFolder_Cache(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg", "b.jpeg")
+	cfg := getTestConfig()
+	cache := &cacheMock{entries: map[string]CacheEntry{}}
+	named := filepath.Join(root, expectedFileNameForValidDateJpeg+jpeg)
+
+	// The first run extracts the metadata and caches the date under the new name
+	et := &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+	results := []Result{}
+	opts := Options{FS: fsys, Cache: cache, Observer: ResultFunc(func(res Result) { results = append(results, res) })}
+	assert.NoError(t, Folder(context.Background(), et, cfg, filepath.Join(root, "a.jpeg"), opts))
+	assert.Len(t, et.files, 1)
+	assert.Equal(t, StatusRenamed, results[0].Status)
+
+	// Metadata errors are not cached
+	assert.NoError(t, Folder(context.Background(), &extractorMock{}, cfg, filepath.Join(root, "b.jpeg"), opts))
+	assert.Len(t, cache.entries, 1)
+
+	// Next runs use the cache
+	et = &extractorMock{}
+	results = []Result{}
+	assert.NoError(t, Folder(context.Background(), et, cfg, named, opts))
+	assert.Empty(t, et.files)
+	assert.Equal(t, StatusAlreadyNamed, results[0].Status)
+	assert.Equal(t, validDateKeyForJpeg, results[0].DateField)
+
+	// Unless the configuration of the file type changes
+	other, err := config.LoadConfig([]byte("- extension: .jpeg\n  dateFields:\n    - name: CreateDate\n      dateFormat: \"2006:01:02 15:04:05\"\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, Folder(context.Background(), et, other, named, opts))
+	assert.Len(t, et.files, 1)
+}
+
+func TestFolder_Progress(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg", "sub/b.jpeg", "c.txt", "d.mov")
+	cfg := getTestConfig()
+
+	// Discovery counts the files that would be processed
+	count, err := Discover(context.Background(), cfg, root, Options{FS: fsys, Exclude: []string{"d.mov"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	progress := &Progress{}
+	et := &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+	assert.NoError(t, Folder(context.Background(), et, cfg, root, Options{FS: fsys, DryRun: true, Observer: progress}))
+	assert.Equal(t, int64(3), progress.Processed.Load())
+	assert.Equal(t, int64(0), progress.Renamed.Load())
+	// d.mov has no CreationDate
+	assert.Equal(t, int64(1), progress.Failed.Load())
+}
+
+func TestFiles(t *testing.T) {
+	fsys, root := newFS(t,
+		"a.jpeg",
+		"b.txt",
+		".hidden.jpeg",
+		"@eaDir/c.jpeg",
+		"deep/er/d.jpeg",
+		"ignored/e.jpeg",
+		"sub/f.jpeg",
+	)
+	assert.NoError(t, fsys.WriteFile(filepath.Join(root, "sub", filter.IgnoreFileName), []byte("f.jpeg\n"), 0644))
+	assert.NoError(t, fsys.WriteFile(filepath.Join(root, filter.IgnoreFileName), []byte("ignored/\n"), 0644))
+	paths := []string{}
+	for _, f := range []string{"a.jpeg", "b.txt", ".hidden.jpeg", "@eaDir/c.jpeg", "deep/er/d.jpeg", "ignored/e.jpeg", "sub/f.jpeg", "missing.jpeg"} {
+		paths = append(paths, filepath.Join(root, filepath.FromSlash(f)))
+	}
+	paths = append(paths, filepath.Join(filepath.Dir(root), "outside.jpeg"))
+
+	// The same files are skipped as when walking the folder
+	et := &extractorMock{}
+	opts := Options{FS: fsys, Exclude: []string{"@eaDir"}, MaxDepth: 2}
+	assert.NoError(t, Files(context.Background(), et, getTestConfig(), root, paths, opts))
+	assert.Equal(t, []string{filepath.Join(root, "a.jpeg")}, et.files)
+	assert.Equal(t, []string{"a.jpeg"}, processedFiles(t, fsys, getTestConfig(), root, opts))
+}
+
+func TestDirSkipper(t *testing.T) {
+	fsys, root := newFS(t, "@eaDir/a.jpeg", "deep/er/b.jpeg", "ignored/c.jpeg", "sub/d.jpeg")
+	assert.NoError(t, fsys.WriteFile(filepath.Join(root, filter.IgnoreFileName), []byte("ignored/\n"), 0644))
+
+	// The same folders are skipped as when walking the folder
+	skip, err := DirSkipper(getTestConfig(), root, Options{FS: fsys, Exclude: []string{"@eaDir"}, MaxDepth: 2})
+	assert.NoError(t, err)
+	assert.True(t, skip(filepath.Join(root, "@eaDir")))
+	assert.True(t, skip(filepath.Join(root, "deep", "er")))
+	assert.True(t, skip(filepath.Join(root, "ignored")))
+	assert.False(t, skip(filepath.Join(root, "deep")))
+	assert.False(t, skip(filepath.Join(root, "sub")))
+
+	_, err = DirSkipper(getTestConfig(), root, Options{Exclude: []string{"["}})
+	assert.Error(t, err)
+}
+
+func TestFolder_Canceled(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg", "b.jpeg", "c.jpeg")
+	et := &extractorMock{fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}}
+
+	// The file being processed when the context is canceled is completed
+	ctx, cancel := context.WithCancel(context.Background())
+	results := []Result{}
+	opts := Options{FS: fsys, DryRun: true, Observer: ResultFunc(func(res Result) {
+		results = append(results, res)
+		cancel()
+	})}
+	err := Folder(ctx, et, getTestConfig(), root, opts)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, et.files, 1)
+	assert.Len(t, results, 1)
+	assert.Equal(t, StatusPlanned, results[0].Status)
+
+	// Nothing is processed with a done context
+	et.files = nil
+	assert.ErrorIs(t, Files(ctx, et, getTestConfig(), root, []string{filepath.Join(root, "a.jpeg")}, opts), context.Canceled)
+	_, err = Discover(ctx, getTestConfig(), root, opts)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, et.files)
+}
+
+func TestFolder_InvalidPattern(t *testing.T) {
+	fsys, root := newFS(t)
+	err := Folder(context.Background(), &extractorMock{}, getTestConfig(), root, Options{FS: fsys, Exclude: []string{"[unclosed"}})
+	assert.Error(t, err)
+}
+
+///////////////////////////////////
+//			Roots
+///////////////////////////////////
+
+func TestRoots(t *testing.T) {
+	base := t.TempDir()
+	abs := func(p string) string { return filepath.Join(base, filepath.FromSlash(p)) }
+
+	roots, err := Roots([]string{
+		abs("trip/day2"),
+		abs("trip b"),
+		abs("trip"),
+		abs("trip/day1/IMG_0001.jpeg"),
+		abs("other/IMG_0002.jpeg"),
+		abs("trip"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{abs("other/IMG_0002.jpeg"), abs("trip"), abs("trip b")}, roots)
+
+	// Relative paths are made absolute
+	roots, err = Roots([]string{"."})
+	assert.NoError(t, err)
+	wd, _ := os.Getwd()
+	assert.Equal(t, []string{wd}, roots)
+}
+
+func TestFolder_SingleFile(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg", "b.jpeg")
+
+	et := &extractorMock{}
+	assert.NoError(t, Folder(context.Background(), et, getTestConfig(), filepath.Join(root, "b.jpeg"), Options{FS: fsys}))
+	assert.Equal(t, []string{filepath.Join(root, "b.jpeg")}, et.files)
+}
+
+func TestFolder_SingleFileFilters(t *testing.T) {
+	cfg, err := config.Resolve(config.Layer{Source: "test", Bytes: configFile}, config.Layer{Source: "filters", Bytes: []byte("include: [\"*.jpeg\"]\nexclude: [\"*.tmp.jpeg\"]\n")})
+	assert.NoError(t, err)
+	fsys, root := newFS(t, "a.jpeg", "b.tmp.jpeg")
+
+	// A file given as root is filtered by its name
+	et := &extractorMock{}
+	assert.NoError(t, Folder(context.Background(), et, cfg, filepath.Join(root, "a.jpeg"), Options{FS: fsys}))
+	assert.NoError(t, Folder(context.Background(), et, cfg, filepath.Join(root, "b.tmp.jpeg"), Options{FS: fsys}))
+	assert.Equal(t, []string{filepath.Join(root, "a.jpeg")}, et.files)
+
+	// As are the files listed in a folder
+	et = &extractorMock{}
+	files := []string{filepath.Join(root, "a.jpeg"), filepath.Join(root, "b.tmp.jpeg")}
+	assert.NoError(t, Files(context.Background(), et, cfg, root, files, Options{FS: fsys}))
+	assert.Equal(t, []string{filepath.Join(root, "a.jpeg")}, et.files)
+	count, err := DiscoverFiles(context.Background(), cfg, root, files, Options{FS: fsys})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestTargets(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg", "b.jpeg", "sub/c.jpeg", "other/d.jpeg")
+	targets, err := Targets(fsys, []string{
+		filepath.Join(root, "b.jpeg"),
+		filepath.Join(root, "other"),
+		filepath.Join(root, "a.jpeg"),
+		filepath.Join(root, "sub", "c.jpeg"),
+		filepath.Join(root, "missing"),
+	})
+	assert.NoError(t, err)
+
+	// Files are grouped by folder, missing paths are walked to report them
+	assert.Equal(t, []Target{
+		{Root: root, Files: []string{filepath.Join(root, "a.jpeg"), filepath.Join(root, "b.jpeg")}},
+		{Root: filepath.Join(root, "missing")},
+		{Root: filepath.Join(root, "other")},
+		{Root: filepath.Join(root, "sub"), Files: []string{filepath.Join(root, "sub", "c.jpeg")}},
+	}, targets)
+}
+
 ///////////////////////////////////
 //			tryGetDate
 ///////////////////////////////////
@@ -77,34 +818,129 @@ func TestTryGetDate_Success(t *testing.T) {
 	fileConfig, err := getTestConfig().FileConfig(jpeg)
 	assert.NoError(t, err)
 
-	date, err := tryGetDate(validImagePath, fileConfig, validDateKeyForJpeg, validDateValueForJpeg)
+	fields := map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}
+	date, err := tryGetDate(fileConfig, fields)
 	assert.NoError(t, err)
-	assert.Equal(t, expectedFileNameForValidDateJpeg, date)
+	assert.Equal(t, expectedFileNameForValidDateJpeg, date.name)
+	assert.Equal(t, validDateKeyForJpeg, date.field)
+	assert.Equal(t, validDateValueForJpeg, date.raw)
+}
+
+func TestTryGetDate_Priority(t *testing.T) {
+	fileConfig, err := getTestConfig().FileConfig(jpeg)
+	assert.NoError(t, err)
+
+	// RandomKey is configured before CreateDate so it wins
+	fields := map[string]interface{}{
+		validDateKeyForJpeg: validDateValueForJpeg,
+		"RandomKey":         "2001:02:03 04:05:06",
+	}
+	date, err := tryGetDate(fileConfig, fields)
+	assert.NoError(t, err)
+	assert.Equal(t, "2001_02_03_04_05_06", date.name)
+
+	// Unless it cannot be parsed
+	fields["RandomKey"] = wrongDateValue
+	date, err = tryGetDate(fileConfig, fields)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedFileNameForValidDateJpeg, date.name)
 }
 
 func TestTryGetDate_ErrorDateNotExisting(t *testing.T) {
 	fileConfig, err := getTestConfig().FileConfig(jpeg)
 	assert.NoError(t, err)
 
-	_, err = tryGetDate(imagePathWrongExtension, fileConfig, wrongDateKeyForJpeg, validDateValueForJpeg)
-	assert.Error(t, err)
+	fields := map[string]interface{}{wrongDateKeyForJpeg: validDateValueForJpeg}
+	_, err = tryGetDate(fileConfig, fields)
+	assert.ErrorIs(t, err, ErrNoDate)
 }
 
 func TestTryGetDate_ErrorCannotParseDate(t *testing.T) {
 	fileConfig, err := getTestConfig().FileConfig(jpeg)
 	assert.NoError(t, err)
 
-	_, err = tryGetDate(imagePathWrongExtension, fileConfig, validDateKeyForJpeg, wrongDateValue)
-	assert.Error(t, err)
+	fields := map[string]interface{}{validDateKeyForJpeg: wrongDateValue}
+	_, err = tryGetDate(fileConfig, fields)
+	assert.ErrorIs(t, err, ErrInvalidDate)
+}
+
+///////////////////////////////////
+//			default profiles
+///////////////////////////////////
+
+// Expected file name for the metadata fixture of each default extension
+var defaultProfileNames = map[string]string{
+	".jpg":  "2021_05_23_08_05_12",
+	".jpeg": "2021_05_23_08_05_12",
+	".heic": "2021_05_23_08_05_12",
+	".heif": "2021_05_23_08_05_12",
+	".webp": "2021_05_23_08_05_12",
+	".tif":  "2021_05_23_08_05_12",
+	".dng":  "2021_05_23_08_05_12",
+	".cr2":  "2021_05_23_08_05_12",
+	".cr3":  "2021_05_23_08_05_12",
+	".nef":  "2021_05_23_08_05_12",
+	".arw":  "2021_05_23_08_05_12",
+	".orf":  "2021_05_23_08_05_12",
+	".rw2":  "2021_05_23_08_05_12",
+	".raf":  "2021_05_23_08_05_12",
+	".png":  "2020_02_03_04_05_06",
+	".gif":  "2018_12_24_20_30_40",
+	".mov":  "2015_07_15_13_56_17",
+	".mp4":  "2019_08_05_12_12_13",
+	".m4v":  "2015_07_15_13_56_17",
+	".3gp":  "2015_07_15_13_56_17",
+	".avi":  "2009_09_11_11_24_05",
+	".mts":  "2013_05_04_12_00_00",
+	".m2ts": "2013_05_04_12_00_00",
+	".mkv":  "2019_03_06_10_29_34",
+}
+
+// loadMetadataFixture reads the exiftool -json output stored for an extension
+func loadMetadataFixture(t *testing.T, ext string) map[string]interface{} {
+	bytes, err := metadataFixtures.ReadFile("testdata/metadata/sample" + ext + ".json")
+	assert.NoError(t, err)
+
+	var fields []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(bytes, &fields))
+	assert.Equal(t, 1, len(fields))
+	return fields[0]
+}
+
+func TestDefaultProfiles(t *testing.T) {
+	cfg, err := config.Resolve(config.DefaultLayer())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, cfg.Extensions(), keys(defaultProfileNames))
+
+	for _, ext := range cfg.Extensions() {
+		t.Run(ext, func(t *testing.T) {
+			fileConfig, err := cfg.FileConfig(ext)
+			assert.NoError(t, err)
+
+			date, err := tryGetDate(fileConfig, loadMetadataFixture(t, ext))
+			assert.NoError(t, err)
+			assert.Equal(t, defaultProfileNames[ext], date.name)
+		})
+	}
+}
+
+func keys(m map[string]string) []string {
+	res := []string{}
+	for k := range m {
+		res = append(res, k)
+	}
+	return res
 }
 
 ///////////////////////////////////
-//			tryRename
+//			planRename and Execute
 ///////////////////////////////////
 
-// renamerMock mocks process.Renamer
+// renamerMock mocks the renames of a filesystem, the rest of the calls go
+// to the embedded one
 type renamerMock struct {
 	mock.Mock
+	filesystem.FS
 }
 
 func (d *renamerMock) Rename(oldpath string, newpath string) error {
@@ -112,90 +948,81 @@ func (d *renamerMock) Rename(oldpath string, newpath string) error {
 	return args.Error(0)
 }
 
-func TestTryRename_Success(t *testing.T) {
+func TestPlanRename_Success(t *testing.T) {
 	cfg := getTestConfig()
-	path := validImagePath
-	renamer := renamerMock{}
+	fsys, root := newFS(t, validImagePath)
+	path := filepath.Join(root, validImagePath)
+	renamer := renamerMock{FS: fsys}
 	fileInfo := &exiftool.FileMetadata{
 		File:   path,
 		Fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg},
 		Err:    nil,
 	}
 
-	renamer.On("Rename", path, mock.Anything).Return(nil).Once()
-	err := tryRename(path, cfg, &renamer, *fileInfo, false)
+	res, err := planRename(path, cfg, *fileInfo, Options{}, BaseObserver{})
 	assert.NoError(t, err)
+	assert.Equal(t, StatusPlanned, res.Status)
+	assert.Equal(t, filepath.Join(root, expectedFileNameForValidDateJpeg+jpeg), res.NewPath)
+	assert.Equal(t, validDateKeyForJpeg, res.DateField)
+	assert.Equal(t, validDateValueForJpeg, res.RawDate)
+	assert.Equal(t, 2019, res.Time.Year())
+
+	var logs bytes.Buffer
+	logger, _ := logging.New(&logs, "info", logging.FormatText)
+	renamer.On("Rename", path, res.NewPath).Return(nil).Once()
+	res = Execute(res, cfg, Options{FS: &renamer, Observer: &LogObserver{Logger: logger}})
+	assert.Contains(t, logs.String(), "level=INFO msg=Renamed")
 	renamer.AssertExpectations(t)
+	assert.Equal(t, StatusRenamed, res.Status)
+	assert.Equal(t, filepath.Join(root, expectedFileNameForValidDateJpeg+jpeg), res.NewPath)
 }
 
-func TestTryRename_ErrorRenaming(t *testing.T) {
+func TestExecute_ErrorRenaming(t *testing.T) {
 	cfg := getTestConfig()
-	path := validImagePath
-	renamer := renamerMock{}
+	fsys, root := newFS(t, validImagePath)
+	path := filepath.Join(root, validImagePath)
+	renamer := renamerMock{FS: fsys}
 	fileInfo := &exiftool.FileMetadata{
 		File:   path,
 		Fields: map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg},
 		Err:    nil,
 	}
 
+	res, err := planRename(path, cfg, *fileInfo, Options{}, BaseObserver{})
+	assert.NoError(t, err)
 	renamer.On("Rename", path, mock.Anything).Return(errors.New("error renaming")).Once()
-	err := tryRename(path, cfg, &renamer, *fileInfo, false)
-	assert.Error(t, err)
+	res = Execute(res, cfg, Options{FS: &renamer})
 	renamer.AssertExpectations(t)
+	assert.Equal(t, StatusFailed, res.Status)
+	assert.Equal(t, ClassRename, res.ErrorClass)
 }
 
-func TestTryRename_ErrorCannotFindDate(t *testing.T) {
+func TestPlanRename_ErrorCannotFindDate(t *testing.T) {
 	cfg := getTestConfig()
 	path := validImagePath
-	renamer := renamerMock{}
 	fileInfo := &exiftool.FileMetadata{
 		File:   path,
 		Fields: map[string]interface{}{wrongDateKeyForJpeg: validDateValueForJpeg},
 		Err:    nil,
 	}
 
-	err := tryRename(path, cfg, &renamer, *fileInfo, false)
+	res, err := planRename(path, cfg, *fileInfo, Options{}, BaseObserver{})
 	assert.Error(t, err)
+	assert.Equal(t, ClassNoDate, res.ErrorClass)
 }
 
-func TestTryRename_ErrorWrongExtension(t *testing.T) {
+func TestPlanRename_ErrorWrongExtension(t *testing.T) {
 	cfg := getTestConfig()
 	path := imagePathWrongExtension
-	renamer := renamerMock{}
 	fileInfo := &exiftool.FileMetadata{
 		File:   path,
 		Fields: map[string]interface{}{wrongDateKeyForJpeg: validDateValueForJpeg},
 		Err:    nil,
 	}
 
-	err := tryRename(path, cfg, &renamer, *fileInfo, false)
-	assert.Error(t, err)
-}
-
-///////////////////////////////////
-//			NewFileName
-///////////////////////////////////
-
-func TestNewFileName_JPEG(t *testing.T) {
-	fnameJPEG, err := newFileName(validDateFormatJpeg, validDateValueForJpeg)
-	assert.NoError(t, err)
-	assert.Equal(t, fnameJPEG, expectedFileNameForValidDateJpeg)
-}
-
-func TestNewFileName_MOV(t *testing.T) {
-	dateMOV := validDateMOV
-	expected := expectedFileNameForValidDateMov
-
-	fnameMOV, err := newFileName(validDateFormatMOV, dateMOV)
-	assert.NoError(t, err)
-	assert.Equal(t, fnameMOV, expected)
-}
-
-func TestNewFileName_Error(t *testing.T) {
-	dateMOV := validDateMOV
-
-	_, err := newFileName(wrongDateFormat, dateMOV)
+	res, err := planRename(path, cfg, *fileInfo, Options{}, BaseObserver{})
 	assert.Error(t, err)
+	assert.Equal(t, ClassUnsupported, res.ErrorClass)
 }
 
 ///////////////////////////////////
@@ -0,0 +1,61 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import "sync/atomic"
+
+// Progress is an Observer counting the files of a run. It can be read
+// concurrently, e.g. to display the progress.
+type Progress struct {
+	BaseObserver
+
+	// Discovered is the total number of files to process, set by the caller
+	Discovered atomic.Int64
+	Processed  atomic.Int64
+	Renamed    atomic.Int64
+	// AlreadyNamed counts the files whose name already matches their date
+	AlreadyNamed atomic.Int64
+	Failed       atomic.Int64
+}
+
+func (p *Progress) FileRenamed(res Result) {
+	p.count(res)
+}
+
+func (p *Progress) FileFailed(res Result) {
+	p.count(res)
+}
+
+// count updates the counters with the result of a file
+func (p *Progress) count(res Result) {
+	p.Processed.Add(1)
+	switch res.Status {
+	case StatusRenamed:
+		p.Renamed.Add(1)
+	case StatusAlreadyNamed:
+		p.AlreadyNamed.Add(1)
+	case StatusFailed:
+		p.Failed.Add(1)
+	}
+}
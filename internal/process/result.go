@@ -0,0 +1,157 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"errors"
+	"time"
+)
+
+// Status is the outcome of processing a file
+type Status string
+
+const (
+	StatusRenamed Status = "renamed"
+	StatusFailed  Status = "failed"
+	// StatusPlanned is a rename computed in a dry run but not executed
+	StatusPlanned Status = "planned"
+	// StatusSkipped is a planned rename that was not executed on purpose
+	StatusSkipped Status = "skipped"
+	// StatusAlreadyNamed is a file whose name already matches its date
+	StatusAlreadyNamed Status = "already-named"
+)
+
+// ErrorClass groups the reasons why a file could not be renamed
+type ErrorClass string
+
+const (
+	ClassMetadata      ErrorClass = "metadata"
+	ClassUnsupported   ErrorClass = "unsupported"
+	ClassNoDate        ErrorClass = "no-date"
+	ClassInvalidDate   ErrorClass = "invalid-date"
+	ClassRename        ErrorClass = "rename"
+	ClassCollision     ErrorClass = "collision"
+	ClassVetoed        ErrorClass = "vetoed"
+	ClassTimes         ErrorClass = "timestamps"
+	ClassMetadataWrite ErrorClass = "metadata-write"
+)
+
+var (
+	ErrMetadata      = errors.New("could not extract metadata")
+	ErrUnsupported   = errors.New("could not find a configuration for the extension")
+	ErrNoDate        = errors.New("none of the configured date fields is present in metadata")
+	ErrInvalidDate   = errors.New("none of the configured date fields could be parsed")
+	ErrRename        = errors.New("could not rename file")
+	ErrCollision     = errors.New("the new name is taken by another file")
+	ErrVetoed        = errors.New("the rename was vetoed by the preRename hook")
+	ErrTimes         = errors.New("could not set the timestamps")
+	ErrMetadataWrite = errors.New("could not write the metadata")
+)
+
+// Result is the outcome of processing a file
+type Result struct {
+	Path      string     `json:"path"`
+	NewPath   string     `json:"newPath,omitempty"`
+	Status    Status     `json:"status"`
+	DateField string     `json:"dateField,omitempty"`
+	RawDate   string     `json:"rawDate,omitempty"`
+	Time      *time.Time `json:"time,omitempty"`
+	// Shift is the correction included in Time, e.g., "1h30m0s"
+	Shift      string     `json:"shift,omitempty"`
+	ErrorClass ErrorClass `json:"errorClass,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	// HookError is the error of the postRename hook of a renamed file
+	HookError string `json:"hookError,omitempty"`
+
+	// tags are the metadata tags written once the file is renamed, see
+	// Options.Metadata
+	tags map[string]string
+}
+
+// fail marks the result as failed because of err
+func (r *Result) fail(err error) {
+	r.Status = StatusFailed
+	r.ErrorClass = errorClass(err)
+	r.Error = err.Error()
+}
+
+// errorClass returns the class of an error returned while processing a file
+func errorClass(err error) ErrorClass {
+	switch {
+	case errors.Is(err, ErrMetadata):
+		return ClassMetadata
+	case errors.Is(err, ErrUnsupported):
+		return ClassUnsupported
+	case errors.Is(err, ErrNoDate):
+		return ClassNoDate
+	case errors.Is(err, ErrInvalidDate):
+		return ClassInvalidDate
+	case errors.Is(err, ErrCollision):
+		return ClassCollision
+	case errors.Is(err, ErrVetoed):
+		return ClassVetoed
+	case errors.Is(err, ErrTimes):
+		return ClassTimes
+	case errors.Is(err, ErrMetadataWrite):
+		return ClassMetadataWrite
+	default:
+		return ClassRename
+	}
+}
+
+// classErrors maps each error class to its sentinel error
+var classErrors = map[ErrorClass]error{
+	ClassMetadata:      ErrMetadata,
+	ClassUnsupported:   ErrUnsupported,
+	ClassNoDate:        ErrNoDate,
+	ClassInvalidDate:   ErrInvalidDate,
+	ClassRename:        ErrRename,
+	ClassCollision:     ErrCollision,
+	ClassVetoed:        ErrVetoed,
+	ClassTimes:         ErrTimes,
+	ClassMetadataWrite: ErrMetadataWrite,
+}
+
+// Err returns the error of a failed result, nil otherwise. The error wraps
+// the sentinel error of its class so it can be checked with errors.Is.
+func (r Result) Err() error {
+	if r.Status != StatusFailed {
+		return nil
+	}
+	return &resultError{class: r.ErrorClass, msg: r.Error}
+}
+
+// resultError is the error of a failed result
+type resultError struct {
+	class ErrorClass
+	msg   string
+}
+
+func (e *resultError) Error() string {
+	return e.msg
+}
+
+func (e *resultError) Unwrap() error {
+	return classErrors[e.class]
+}
@@ -0,0 +1,89 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/barasher/go-exiftool"
+	"github.com/lluissm/media-renamer/internal/config"
+)
+
+// cameraSerialFields are the tags holding the serial number of a camera,
+// in priority order
+var cameraSerialFields = []string{"SerialNumber", "InternalSerialNumber"}
+
+// CameraOf returns the camera that took a file according to its metadata
+func CameraOf(fileInfo exiftool.FileMetadata) config.Camera {
+	camera := config.Camera{}
+	camera.Make, _ = fileInfo.GetString("Make")
+	camera.Model, _ = fileInfo.GetString("Model")
+	for _, field := range cameraSerialFields {
+		if serial, err := fileInfo.GetString(field); err == nil {
+			camera.Serial = serial
+			break
+		}
+	}
+	return camera
+}
+
+// shiftOffset returns the duration added to the date t of a file: the
+// offset of the last shift rule of cfg it matches plus the shift of the
+// options. Files whose dates were already shifted and written are not
+// shifted again.
+func shiftOffset(cfg *config.Config, path string, fileInfo exiftool.FileMetadata, t time.Time, shift time.Duration) time.Duration {
+	if shiftedBefore(fileInfo.Fields) {
+		return 0
+	}
+	var offset time.Duration
+	if rules := cfg.Shifts(); len(rules) > 0 {
+		camera := CameraOf(fileInfo)
+		for _, rule := range rules {
+			if rule.Matches(camera, filepath.Dir(path), t) {
+				offset = rule.Offset()
+			}
+		}
+	}
+	return offset + shift
+}
+
+// shiftDate adds an offset to a date and names it after the new time
+func shiftDate(date *dateMatch, offset time.Duration) {
+	if offset == 0 {
+		return
+	}
+	date.shift = offset
+	date.time = date.time.Add(offset)
+	date.name = formatFileName(date.time)
+}
+
+// formatShift returns the offset of a shifted date as reported in its
+// result, empty if it is not shifted
+func formatShift(offset time.Duration) string {
+	if offset == 0 {
+		return ""
+	}
+	return offset.String()
+}
@@ -0,0 +1,91 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/barasher/go-exiftool"
+	"github.com/lluissm/media-renamer/internal/config"
+)
+
+// SyncRule returns the shift rule that puts the files of the camera that
+// took target on the clock of the camera that took reference, two photos
+// taken at the same time. The date of reference is shifted by the rules of
+// cfg, the rule replaces the ones matching the files of target.
+func SyncRule(et Extractor, cfg *config.Config, reference, target string) (config.ShiftRule, error) {
+	refInfo, refDate, err := syncDate(et, cfg, reference)
+	if err != nil {
+		return config.ShiftRule{}, err
+	}
+	targetInfo, targetDate, err := syncDate(et, cfg, target)
+	if err != nil {
+		return config.ShiftRule{}, err
+	}
+	refDate.time = refDate.time.Add(shiftOffset(cfg, reference, refInfo, refDate.time, 0))
+
+	camera := CameraOf(targetInfo)
+	if camera == (config.Camera{}) {
+		return config.ShiftRule{}, fmt.Errorf("no camera make, model or serial number in the metadata of %s", target)
+	}
+	offset := wallClock(refDate.time).Sub(wallClock(targetDate.time))
+	shift := config.Shift{Make: camera.Make, Model: camera.Model, Serial: camera.Serial, Offset: offset.String()}
+	rule, err := config.NewShiftRule(shift, fmt.Sprintf("sync %s=%s", reference, target))
+	if err != nil {
+		return rule, err
+	}
+	if rule.Matches(CameraOf(refInfo), filepath.Dir(reference), refDate.time) {
+		return rule, fmt.Errorf("%s and %s were taken by the same camera", reference, target)
+	}
+	return rule, nil
+}
+
+// syncDate returns the metadata and the date of a reference file
+func syncDate(et Extractor, cfg *config.Config, path string) (exiftool.FileMetadata, *dateMatch, error) {
+	fileInfos := et.ExtractMetadata(path)
+	if len(fileInfos) == 0 {
+		return exiftool.FileMetadata{}, nil, fmt.Errorf("%w: no metadata for %s", ErrMetadata, path)
+	}
+	fileInfo := fileInfos[0]
+	if fileInfo.Err != nil {
+		return fileInfo, nil, fmt.Errorf("%w: %s: %v", ErrMetadata, path, fileInfo.Err)
+	}
+	fileConfig, err := cfg.FileConfig(filepath.Ext(path))
+	if err != nil {
+		return fileInfo, nil, fmt.Errorf("%w %s", ErrUnsupported, filepath.Ext(path))
+	}
+	date, err := tryGetDate(fileConfig, fileInfo.Fields)
+	if err != nil {
+		return fileInfo, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return fileInfo, date, nil
+}
+
+// wallClock returns the time of the clock t was read from, ignoring its
+// time zone, as the names of the files do
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
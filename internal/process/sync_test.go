@@ -0,0 +1,78 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/barasher/go-exiftool"
+	"github.com/stretchr/testify/assert"
+)
+
+// filesMock returns the metadata of each file by its name
+type filesMock map[string]map[string]interface{}
+
+func (e filesMock) ExtractMetadata(files ...string) []exiftool.FileMetadata {
+	res := []exiftool.FileMetadata{}
+	for _, f := range files {
+		res = append(res, exiftool.FileMetadata{File: f, Fields: e[filepath.Base(f)]})
+	}
+	return res
+}
+
+func TestSyncRule(t *testing.T) {
+	fsys, root := newFS(t, "a.jpeg", "b.jpeg", "c.jpeg")
+	et := filesMock{
+		"a.jpeg": {validDateKeyForJpeg: validDateValueForJpeg, "Make": "Canon"},
+		"b.jpeg": {validDateKeyForJpeg: "2019:08:05 16:10:00", "Make": "Sony", "SerialNumber": "42"},
+		"c.jpeg": {validDateKeyForJpeg: "2019:08:05 16:30:00", "Make": "Sony", "SerialNumber": "42"},
+	}
+	cfg := getTestConfig()
+
+	rule, err := SyncRule(et, cfg, filepath.Join(root, "a.jpeg"), filepath.Join(root, "b.jpeg"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Sony", rule.Make)
+	assert.Equal(t, "42", rule.Serial)
+	assert.Equal(t, "-1h57m47s", rule.Shift.Offset)
+
+	// Every file of the second camera is put on the clock of the first one
+	results := map[string]Result{}
+	opts := Options{FS: fsys, DryRun: true, Observer: ResultFunc(func(res Result) { results[filepath.Base(res.Path)] = res })}
+	assert.NoError(t, Folder(context.Background(), et, cfg.WithShifts(rule), root, opts))
+	assert.Equal(t, filepath.Join(root, expectedFileNameForValidDateJpeg+jpeg), results["a.jpeg"].NewPath)
+	assert.Empty(t, results["a.jpeg"].Shift)
+	assert.Equal(t, filepath.Join(root, expectedFileNameForValidDateJpeg+"_1"+jpeg), results["b.jpeg"].NewPath)
+	assert.Equal(t, filepath.Join(root, "2019_08_05_14_32_13"+jpeg), results["c.jpeg"].NewPath)
+
+	// The cameras must be told apart by their metadata
+	_, err = SyncRule(et, cfg, filepath.Join(root, "b.jpeg"), filepath.Join(root, "c.jpeg"))
+	assert.Error(t, err)
+	et["c.jpeg"] = map[string]interface{}{validDateKeyForJpeg: validDateValueForJpeg}
+	_, err = SyncRule(et, cfg, filepath.Join(root, "a.jpeg"), filepath.Join(root, "c.jpeg"))
+	assert.Error(t, err)
+	_, err = SyncRule(et, cfg, filepath.Join(root, "a.jpeg"), filepath.Join(root, "d.png"))
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
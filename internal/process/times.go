@@ -0,0 +1,76 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package process
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/filesystem"
+)
+
+// Times selects the timestamps of the files that are set to their date
+type Times struct {
+	// Mtime sets the modification time
+	Mtime bool
+	// Atime sets the access time
+	Atime bool
+	// Unchanged also sets them for the files already named after their date
+	Unchanged bool
+}
+
+// setTimes sets the timestamps selected by times of a renamed file, or of
+// an already named one if times.Unchanged, to its date. A failure is
+// reported in the result, the file keeps its new name.
+func setTimes(fsys filesystem.FS, res Result, times Times) Result {
+	if !times.Mtime && !times.Atime || res.Time == nil {
+		return res
+	}
+	if res.Status != StatusRenamed && (res.Status != StatusAlreadyNamed || !times.Unchanged) {
+		return res
+	}
+
+	t := localTime(*res.Time)
+	var atime, mtime time.Time
+	if times.Atime {
+		atime = t
+	}
+	if times.Mtime {
+		mtime = t
+	}
+	if err := fsys.Chtimes(res.NewPath, atime, mtime); err != nil {
+		res.fail(fmt.Errorf("%w of %s: %v", ErrTimes, res.NewPath, err))
+	}
+	return res
+}
+
+// localTime returns the instant of a date. Most metadata dates have no time
+// zone and are parsed as UTC, their wall clock is taken as local time, as
+// it is for the file names.
+func localTime(t time.Time) time.Time {
+	if t.Location() != time.UTC {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+}
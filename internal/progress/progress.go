@@ -0,0 +1,133 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/process"
+)
+
+const (
+	// ttyInterval is how often the status line is redrawn in a terminal
+	ttyInterval = 200 * time.Millisecond
+	// lineInterval is how often a status line is written when not in a terminal
+	lineInterval = 10 * time.Second
+)
+
+// Display periodically writes the progress of a run. In a terminal it keeps
+// redrawing a single status line, otherwise it writes a new line from time
+// to time so that logs stay readable.
+type Display struct {
+	w        io.Writer
+	tty      bool
+	progress *process.Progress
+	start    time.Time
+	stop     chan struct{}
+	done     sync.WaitGroup
+}
+
+// IsTerminal returns true if the file is a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Start starts displaying the progress in w until Stop is called
+func Start(w io.Writer, tty bool, p *process.Progress) *Display {
+	interval := lineInterval
+	if tty {
+		interval = ttyInterval
+	}
+
+	d := &Display{
+		w:        w,
+		tty:      tty,
+		progress: p,
+		start:    time.Now(),
+		stop:     make(chan struct{}),
+	}
+	d.done.Add(1)
+	go func() {
+		defer d.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.write(time.Now())
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+	return d
+}
+
+// Stop stops the display and writes the final status
+func (d *Display) Stop() {
+	close(d.stop)
+	d.done.Wait()
+	d.write(time.Now())
+	if d.tty {
+		fmt.Fprintln(d.w)
+	}
+}
+
+// write writes the current status
+func (d *Display) write(now time.Time) {
+	line := Status(d.progress, now.Sub(d.start))
+	if d.tty {
+		fmt.Fprintf(d.w, "\r\033[K%s", line)
+	} else {
+		fmt.Fprintln(d.w, line)
+	}
+}
+
+// Status returns a one line summary of the progress after elapsed time
+func Status(p *process.Progress, elapsed time.Duration) string {
+	discovered := p.Discovered.Load()
+	processed := p.Processed.Load()
+
+	percent := 0.0
+	if discovered > 0 {
+		percent = 100 * float64(processed) / float64(discovered)
+	}
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(processed) / elapsed.Seconds()
+	}
+	eta := "?"
+	if rate > 0 && discovered >= processed {
+		remaining := time.Duration(float64(discovered-processed) / rate * float64(time.Second))
+		eta = remaining.Round(time.Second).String()
+	}
+
+	return fmt.Sprintf("%d/%d files (%.1f%%), %d renamed, %d already named, %d failed, %.1f files/s, ETA %s",
+		processed, discovered, percent, p.Renamed.Load(), p.AlreadyNamed.Load(), p.Failed.Load(), rate, eta)
+}
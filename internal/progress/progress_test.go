@@ -0,0 +1,92 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	p := &process.Progress{}
+	assert.Equal(t, "0/0 files (0.0%), 0 renamed, 0 already named, 0 failed, 0.0 files/s, ETA ?", Status(p, 0))
+
+	p.Discovered.Store(100)
+	p.Processed.Store(20)
+	p.Renamed.Store(15)
+	p.Failed.Store(5)
+	assert.Equal(t, "20/100 files (20.0%), 15 renamed, 0 already named, 5 failed, 2.0 files/s, ETA 40s", Status(p, 10*time.Second))
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDisplay_Terminal(t *testing.T) {
+	var out syncBuffer
+	p := &process.Progress{}
+	p.Discovered.Store(2)
+
+	d := Start(&out, true, p)
+	p.Processed.Store(2)
+	time.Sleep(2 * ttyInterval)
+	d.Stop()
+
+	// The line is redrawn and ends with a newline
+	assert.True(t, strings.HasPrefix(out.String(), "\r\033[K"))
+	assert.True(t, strings.HasSuffix(out.String(), "\n"))
+	assert.Contains(t, out.String(), "2/2 files (100.0%)")
+}
+
+func TestDisplay_NotTerminal(t *testing.T) {
+	var out syncBuffer
+	p := &process.Progress{}
+
+	d := Start(&out, false, p)
+	d.Stop()
+
+	// A single final line without control characters
+	assert.Equal(t, 1, strings.Count(out.String(), "\n"))
+	assert.NotContains(t, out.String(), "\r")
+}
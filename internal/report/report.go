@@ -0,0 +1,191 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package report
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/process"
+)
+
+//go:embed report.html
+var htmlTemplate string
+
+var htmlReport = template.Must(template.New("report").Parse(htmlTemplate))
+
+// csvHeader is the first row of the csv report
+var csvHeader = []string{"path", "new_path", "status", "date_field", "raw_date", "time", "error_class", "error"}
+
+// Write writes the report of a run to path, as csv or html depending on its extension
+func Write(path string, results []process.Result) error {
+	var write func(io.Writer, []process.Result) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		write = WriteCSV
+	case ".html", ".htm":
+		write = WriteHTML
+	default:
+		return fmt.Errorf("unknown report format for %s, use .csv or .html", path)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file, results); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteCSV writes one row per processed file
+func WriteCSV(w io.Writer, results []process.Result) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, res := range results {
+		if err := writer.Write([]string{
+			res.Path,
+			res.NewPath,
+			string(res.Status),
+			res.DateField,
+			res.RawDate,
+			formatTime(res.Time),
+			string(res.ErrorClass),
+			res.Error,
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteHTML writes a self-contained page with the counts per status and
+// extension, the files with no usable date and all the results
+func WriteHTML(w io.Writer, results []process.Result) error {
+	return htmlReport.Execute(w, newSummary(results))
+}
+
+type (
+	// summary is the data of the html report
+	summary struct {
+		Generated   string
+		Total       int
+		Statuses    []process.Status
+		ByStatus    []count
+		ByExtension []extensionCount
+		NoDate      []process.Result
+		Results     []row
+	}
+
+	count struct {
+		Name  string
+		Count int
+	}
+
+	extensionCount struct {
+		Extension string
+		Total     int
+		ByStatus  []int
+	}
+
+	row struct {
+		process.Result
+		Extension string
+		Time      string
+	}
+)
+
+// newSummary aggregates the results for the html report
+func newSummary(results []process.Result) *summary {
+	s := &summary{
+		Generated: time.Now().Format(time.RFC1123),
+		Total:     len(results),
+		Statuses:  []process.Status{},
+		NoDate:    []process.Result{},
+		Results:   []row{},
+	}
+
+	byStatus := map[process.Status]int{}
+	byExtension := map[string]map[process.Status]int{}
+	for _, res := range results {
+		if _, ok := byStatus[res.Status]; !ok {
+			s.Statuses = append(s.Statuses, res.Status)
+		}
+		byStatus[res.Status]++
+
+		ext := strings.ToLower(filepath.Ext(res.Path))
+		if byExtension[ext] == nil {
+			byExtension[ext] = map[process.Status]int{}
+		}
+		byExtension[ext][res.Status]++
+
+		switch res.ErrorClass {
+		case process.ClassMetadata, process.ClassNoDate, process.ClassInvalidDate:
+			// Files whose metadata could not be read have no usable date either
+			s.NoDate = append(s.NoDate, res)
+		}
+		s.Results = append(s.Results, row{res, ext, formatTime(res.Time)})
+	}
+
+	sort.Slice(s.Statuses, func(i, j int) bool { return s.Statuses[i] < s.Statuses[j] })
+	for _, status := range s.Statuses {
+		s.ByStatus = append(s.ByStatus, count{string(status), byStatus[status]})
+	}
+
+	extensions := []string{}
+	for ext := range byExtension {
+		extensions = append(extensions, ext)
+	}
+	sort.Strings(extensions)
+	for _, ext := range extensions {
+		c := extensionCount{Extension: ext}
+		for _, status := range s.Statuses {
+			c.ByStatus = append(c.ByStatus, byExtension[ext][status])
+			c.Total += byExtension[ext][status]
+		}
+		s.ByExtension = append(s.ByExtension, c)
+	}
+	return s
+}
+
+// formatTime formats the resolved date of a file, empty if there is none
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
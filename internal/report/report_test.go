@@ -0,0 +1,135 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/process"
+	"github.com/stretchr/testify/assert"
+)
+
+var date = time.Date(2019, 8, 5, 14, 12, 13, 0, time.UTC)
+
+var results = []process.Result{
+	{
+		Path:      "IMG_0001.JPEG",
+		NewPath:   "2019_08_05_14_12_13.JPEG",
+		Status:    process.StatusRenamed,
+		DateField: "CreateDate",
+		RawDate:   "2019:08:05 14:12:13",
+		Time:      &date,
+	},
+	{
+		Path:       "IMG_0002.jpeg",
+		Status:     process.StatusFailed,
+		ErrorClass: process.ClassNoDate,
+		Error:      "no date",
+	},
+	{
+		Path:       "<script>.mov",
+		Status:     process.StatusFailed,
+		ErrorClass: process.ClassRename,
+		Error:      "permission denied",
+	},
+}
+
+func TestWriteCSV(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, WriteCSV(&out, results))
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(rows))
+	assert.Equal(t, csvHeader, rows[0])
+	assert.Equal(t, []string{"IMG_0001.JPEG", "2019_08_05_14_12_13.JPEG", "renamed", "CreateDate", "2019:08:05 14:12:13", "2019-08-05T14:12:13Z", "", ""}, rows[1])
+	assert.Equal(t, "no-date", rows[2][6])
+}
+
+func TestNewSummary(t *testing.T) {
+	s := newSummary(results)
+	assert.Equal(t, 3, s.Total)
+	assert.Equal(t, []process.Status{process.StatusFailed, process.StatusRenamed}, s.Statuses)
+	assert.Equal(t, []count{{"failed", 2}, {"renamed", 1}}, s.ByStatus)
+	assert.Equal(t, []extensionCount{
+		{Extension: ".jpeg", Total: 2, ByStatus: []int{1, 1}},
+		{Extension: ".mov", Total: 1, ByStatus: []int{1, 0}},
+	}, s.ByExtension)
+	assert.Equal(t, 1, len(s.NoDate))
+	assert.Equal(t, "IMG_0002.jpeg", s.NoDate[0].Path)
+}
+
+func TestWriteHTML(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, WriteHTML(&out, results))
+	html := out.String()
+
+	assert.Contains(t, html, "Files with no usable date (1)")
+	assert.Contains(t, html, "2019_08_05_14_12_13.JPEG")
+	// Paths are escaped
+	assert.Contains(t, html, "&lt;script&gt;.mov")
+	// Self-contained: no external scripts or stylesheets
+	assert.NotContains(t, html, "src=")
+	assert.NotContains(t, html, "<link")
+}
+
+func TestWriteHTML_NoDate(t *testing.T) {
+	results := []process.Result{
+		{Path: "a.jpeg", Status: process.StatusFailed, ErrorClass: process.ClassNoDate, Error: "no date"},
+		{Path: "b.jpeg", Status: process.StatusFailed, ErrorClass: process.ClassInvalidDate, Error: "cannot parse"},
+		{Path: "c.jpeg", Status: process.StatusFailed, ErrorClass: process.ClassMetadata, Error: "exiftool failed"},
+		{Path: "d.jpeg", Status: process.StatusFailed, ErrorClass: process.ClassRename, Error: "permission denied"},
+	}
+
+	// Files whose metadata could not be extracted have no usable date
+	s := newSummary(results)
+	assert.Equal(t, results[:3], s.NoDate)
+
+	var out bytes.Buffer
+	assert.NoError(t, WriteHTML(&out, results))
+	assert.Contains(t, out.String(), "Files with no usable date (3)")
+	assert.Contains(t, out.String(), "<tr><td>c.jpeg</td><td>metadata</td><td>exiftool failed</td></tr>")
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, Write(filepath.Join(dir, "report.csv"), results))
+	content, err := os.ReadFile(filepath.Join(dir, "report.csv"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "path,new_path"))
+
+	assert.NoError(t, Write(filepath.Join(dir, "report.HTML"), results))
+	content, err = os.ReadFile(filepath.Join(dir, "report.HTML"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "<!DOCTYPE html>"))
+
+	assert.Error(t, Write(filepath.Join(dir, "report.pdf"), results))
+}
@@ -0,0 +1,218 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package suggest
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/config"
+	"github.com/lluissm/media-renamer/internal/process"
+)
+
+type (
+	// Suggestion is the file type suggested for an extension
+	Suggestion struct {
+		Extension string
+		// Samples is the number of files whose metadata was extracted
+		Samples int
+		// Fields are the candidate date fields, best first
+		Fields []Field
+	}
+
+	// Field is a date tag together with a layout that parses its values
+	Field struct {
+		config.DateField
+		// Coverage is the number of sampled files where the tag parses with the layout
+		Coverage int
+		// Occurrences is the number of sampled files where the tag is present
+		Occurrences int
+	}
+)
+
+// dateValue captures the parts of a date value: date, optional time with
+// fractional seconds, optional time zone and DST suffix
+var dateValue = regexp.MustCompile(`^\d{4}([:\-])\d{2}([:\-])\d{2}(?:([ T])\d{2}:\d{2}:\d{2}(?:\.\d+)?)?(Z|[+\-]\d{2}:\d{2}|[+\-]\d{4})?( DST)?$`)
+
+// systemTags are set by exiftool from the filesystem, not from the metadata
+// of the file, so they are never suggested
+var systemTags = map[string]bool{
+	"FileModifyDate":      true,
+	"FileAccessDate":      true,
+	"FileInodeChangeDate": true,
+	"FileCreateDate":      true,
+}
+
+// Suggest samples up to samples files per extension in root and suggests
+// the date fields of each extension, ranked by how many files they cover
+// and how consistently they parse
+func Suggest(et process.Extractor, root string, samples int) ([]Suggestion, error) {
+	files, err := sample(root, samples)
+	if err != nil {
+		return nil, err
+	}
+
+	exts := []string{}
+	for ext := range files {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+
+	res := []Suggestion{}
+	for _, ext := range exts {
+		res = append(res, suggestExtension(et, ext, files[ext]))
+	}
+	return res, nil
+}
+
+// sample returns up to samples non hidden files per lowercase extension
+func sample(root string, samples int) (map[string][]string, error) {
+	files := map[string][]string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		hidden := path != root && strings.HasPrefix(d.Name(), ".")
+		if d.IsDir() {
+			if hidden {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if hidden || ext == "" || len(files[ext]) >= samples {
+			return nil
+		}
+		files[ext] = append(files[ext], path)
+		return nil
+	})
+	return files, err
+}
+
+// suggestExtension ranks the date fields found in the metadata of the
+// sampled files of an extension
+func suggestExtension(et process.Extractor, ext string, files []string) Suggestion {
+	s := Suggestion{Extension: ext, Fields: []Field{}}
+	occurrences := map[string]int{}
+	coverage := map[config.DateField]int{}
+
+	for _, fileInfo := range et.ExtractMetadata(files...) {
+		if fileInfo.Err != nil {
+			continue
+		}
+		s.Samples++
+		for name, value := range fileInfo.Fields {
+			if systemTags[name] {
+				continue
+			}
+			layout, ok := InferLayout(fmt.Sprintf("%v", value))
+			if !ok && !strings.Contains(name, "Date") {
+				continue
+			}
+			occurrences[name]++
+			if ok {
+				coverage[config.DateField{Name: name, DateFormat: layout}]++
+			}
+		}
+	}
+
+	for field, count := range coverage {
+		s.Fields = append(s.Fields, Field{DateField: field, Coverage: count, Occurrences: occurrences[field.Name]})
+	}
+	sort.Slice(s.Fields, func(i, j int) bool { return s.Fields[i].better(s.Fields[j]) })
+	return s
+}
+
+// better ranks fields by coverage, then by consistency (the share of the
+// occurrences of the tag that parse with the layout) and then by name
+func (f Field) better(other Field) bool {
+	if f.Coverage != other.Coverage {
+		return f.Coverage > other.Coverage
+	}
+	if f.Coverage*other.Occurrences != other.Coverage*f.Occurrences {
+		return f.Coverage*other.Occurrences > other.Coverage*f.Occurrences
+	}
+	if f.Name != other.Name {
+		return f.Name < other.Name
+	}
+	return f.DateFormat < other.DateFormat
+}
+
+// InferLayout returns the go layout that parses a date value, e.g.,
+// "2006:01:02 15:04:05-07:00" for "2019:08:05 14:12:13+02:00"
+func InferLayout(value string) (string, bool) {
+	m := dateValue.FindStringSubmatch(value)
+	if m == nil {
+		return "", false
+	}
+
+	layout := "2006" + m[1] + "01" + m[2] + "02"
+	if m[3] != "" {
+		// Fractional seconds are accepted after the seconds when parsing
+		layout += m[3] + "15:04:05"
+	}
+	switch {
+	case m[4] == "Z":
+		layout += "Z07:00"
+	case len(m[4]) == len("+07:00"):
+		layout += "-07:00"
+	case len(m[4]) == len("+0700"):
+		layout += "-0700"
+	}
+	layout += m[5]
+
+	if config.ValidateLayout(layout) != nil {
+		return "", false
+	}
+	// Values such as 0000:00:00 00:00:00 match the layout but are not dates
+	if _, err := time.Parse(layout, value); err != nil {
+		return "", false
+	}
+	return layout, true
+}
+
+// Write prints the suggestions as a configuration file, with the coverage
+// of each date field as a comment
+func Write(w io.Writer, suggestions []Suggestion) {
+	for _, s := range suggestions {
+		if len(s.Fields) == 0 {
+			fmt.Fprintf(w, "# %s: no date fields found in %d sampled files\n", s.Extension, s.Samples)
+			continue
+		}
+		fmt.Fprintf(w, "# %s: %d sampled files\n", s.Extension, s.Samples)
+		fmt.Fprintf(w, "- extension: %q\n", s.Extension)
+		fmt.Fprintln(w, "  dateFields:")
+		for _, f := range s.Fields {
+			fmt.Fprintf(w, "    # parses in %d/%d files, present in %d\n", f.Coverage, s.Samples, f.Occurrences)
+			fmt.Fprintf(w, "    - name: %q\n", f.Name)
+			fmt.Fprintf(w, "      dateFormat: %q\n", f.DateFormat)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package suggest
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/barasher/go-exiftool"
+	"github.com/lluissm/media-renamer/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// extractorMock returns the fields configured for each file name, or an
+// error if there are none
+type extractorMock struct {
+	fields map[string]map[string]interface{}
+}
+
+func (e *extractorMock) ExtractMetadata(files ...string) []exiftool.FileMetadata {
+	res := []exiftool.FileMetadata{}
+	for _, f := range files {
+		fields, ok := e.fields[filepath.Base(f)]
+		if !ok {
+			res = append(res, exiftool.FileMetadata{File: f, Err: errors.New("no metadata")})
+			continue
+		}
+		res = append(res, exiftool.FileMetadata{File: f, Fields: fields})
+	}
+	return res
+}
+
+func createFiles(t *testing.T, root string, files ...string) {
+	for _, f := range files {
+		path := filepath.Join(root, filepath.FromSlash(f))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+}
+
+func TestInferLayout(t *testing.T) {
+	for value, expected := range map[string]string{
+		"2019:08:05 14:12:13":           "2006:01:02 15:04:05",
+		"2019:08:05 14:12:13.123":       "2006:01:02 15:04:05",
+		"2019:08:05 14:12:13+02:00":     "2006:01:02 15:04:05-07:00",
+		"2019:08:05 14:12:13.45-0700":   "2006:01:02 15:04:05-0700",
+		"2019-08-05T14:12:13Z":          "2006-01-02T15:04:05Z07:00",
+		"2019:08:05 14:12:13+01:00 DST": "2006:01:02 15:04:05-07:00 DST",
+		"2019:08:05":                    "2006:01:02",
+	} {
+		layout, ok := InferLayout(value)
+		assert.True(t, ok, value)
+		assert.Equal(t, expected, layout, value)
+	}
+
+	for _, value := range []string{"0000:00:00 00:00:00", "2019:13:05 14:12:13", "Apple", "14:12:13", ""} {
+		_, ok := InferLayout(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "a.xyz", "b.XYZ", "sub/c.xyz", ".hidden/d.xyz", "e.txt", "f.xyz")
+	et := &extractorMock{fields: map[string]map[string]interface{}{
+		"a.xyz": {
+			"DateTimeOriginal": "2021:05:23 08:05:12",
+			"CreateDate":       "2021:05:23 08:05:12+02:00",
+			"FileModifyDate":   "2023:03:04 18:00:00+01:00",
+			"Make":             "Camera",
+		},
+		"b.XYZ": {
+			"DateTimeOriginal": "2021:05:24 08:05:12",
+			"CreateDate":       "2021:05:24 08:05:12",
+		},
+		"c.xyz": {
+			"DateTimeOriginal": "0000:00:00 00:00:00",
+			"CreateDate":       "2021:05:25 08:05:12+02:00",
+		},
+		"d.xyz": {"DateTimeOriginal": "2021:05:26 08:05:12"},
+		"e.txt": {"FileModifyDate": "2023:03:04 18:00:00+01:00"},
+	}}
+
+	suggestions, err := Suggest(et, root, 20)
+	assert.NoError(t, err)
+	assert.Len(t, suggestions, 2)
+
+	assert.Equal(t, ".txt", suggestions[0].Extension)
+	assert.Empty(t, suggestions[0].Fields)
+
+	// f.xyz has no metadata and the hidden folder is not sampled
+	xyz := suggestions[1]
+	assert.Equal(t, ".xyz", xyz.Extension)
+	assert.Equal(t, 3, xyz.Samples)
+	assert.Equal(t, []Field{
+		{DateField: config.DateField{Name: "CreateDate", DateFormat: "2006:01:02 15:04:05-07:00"}, Coverage: 2, Occurrences: 3},
+		{DateField: config.DateField{Name: "DateTimeOriginal", DateFormat: "2006:01:02 15:04:05"}, Coverage: 2, Occurrences: 3},
+		{DateField: config.DateField{Name: "CreateDate", DateFormat: "2006:01:02 15:04:05"}, Coverage: 1, Occurrences: 3},
+	}, xyz.Fields)
+}
+
+func TestSuggest_Samples(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "a.xyz", "b.xyz", "c.xyz")
+	fields := map[string]interface{}{"CreateDate": "2021:05:23 08:05:12"}
+	et := &extractorMock{fields: map[string]map[string]interface{}{"a.xyz": fields, "b.xyz": fields, "c.xyz": fields}}
+
+	suggestions, err := Suggest(et, root, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, suggestions[0].Samples)
+}
+
+func TestWrite(t *testing.T) {
+	suggestions := []Suggestion{
+		{Extension: ".txt", Samples: 1, Fields: []Field{}},
+		{Extension: ".xyz", Samples: 2, Fields: []Field{
+			{DateField: config.DateField{Name: "CreateDate", DateFormat: "2006:01:02 15:04:05-07:00"}, Coverage: 2, Occurrences: 2},
+		}},
+	}
+	var out bytes.Buffer
+	Write(&out, suggestions)
+	assert.Equal(t, `# .txt: no date fields found in 1 sampled files
+# .xyz: 2 sampled files
+- extension: ".xyz"
+  dateFields:
+    # parses in 2/2 files, present in 2
+    - name: "CreateDate"
+      dateFormat: "2006:01:02 15:04:05-07:00"
+`, out.String())
+
+	// The output is a ready to use configuration file
+	cfg, err := config.LoadConfig(out.Bytes())
+	assert.NoError(t, err)
+	assert.True(t, cfg.FileIsSupported("a.xyz"))
+}
@@ -0,0 +1,286 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package watch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/lluissm/media-renamer/internal/logging"
+)
+
+// DefaultStable is the default time a file must stay unchanged to be processed
+const DefaultStable = 2 * time.Second
+
+// Options tune how watch.Run detects the files that are ready
+type Options struct {
+	// Stable is the time the size and modification time of a file must stay
+	// unchanged, without any process writing to it, before it is processed
+	Stable time.Duration
+	// Logger receives the events of the watcher, nothing is logged if nil
+	Logger *slog.Logger
+	// SkipDir, if set, returns true for the subfolders of root that are not
+	// watched, e.g., the excluded or ignored ones
+	SkipDir func(dir string) bool
+}
+
+type (
+	// pending is a file that changed recently and is not stable yet
+	pending struct {
+		size    int64
+		modTime time.Time
+		since   time.Time
+	}
+
+	// watcher tracks the pending files of a folder and its subfolders
+	watcher struct {
+		root    string
+		opts    Options
+		logger  *slog.Logger
+		notify  *fsnotify.Watcher
+		pending map[string]*pending
+		// written are the paths written by handle, with the time until
+		// which their events are ignored
+		written map[string]time.Time
+		// busy is set while handle runs, done receives what it returns
+		busy    bool
+		done    chan []string
+		now     func() time.Time
+		handle  func(paths []string) []string
+		writers func(paths []string) map[string]bool
+	}
+)
+
+// Run watches root and its subfolders and calls handle with the files that
+// are created or modified, once they are stable. The files already present
+// are handled too. Events are only recorded as they arrive, so bursts of
+// thousands of files are handled in batches. handle runs in its own
+// goroutine, one batch at a time, so events keep being recorded while it
+// processes a slow file. It returns the paths it wrote, e.g., the new names
+// of the renamed files, whose events are ignored for the stable interval so
+// they are not handled again. Run returns once ctx is done and the running
+// handle, if any, has returned.
+func Run(ctx context.Context, root string, opts Options, handle func(paths []string) []string) error {
+	if opts.Stable <= 0 {
+		opts.Stable = DefaultStable
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = logging.Discard()
+	}
+	notify, err := fsnotify.NewBufferedWatcher(1024)
+	if err != nil {
+		return err
+	}
+	defer notify.Close()
+
+	w := &watcher{
+		root:    root,
+		opts:    opts,
+		logger:  logger,
+		notify:  notify,
+		pending: map[string]*pending{},
+		written: map[string]time.Time{},
+		done:    make(chan []string, 1),
+		now:     time.Now,
+		handle:  handle,
+		writers: openForWriting,
+	}
+	defer w.wait()
+	if err := w.add(root); err != nil {
+		return err
+	}
+	logger.Info("Watching", "path", root, "stable", opts.Stable)
+
+	// Pending files are checked often enough to handle them soon after they
+	// have been stable for the configured time
+	ticker := time.NewTicker(max(opts.Stable/4, 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-notify.Events:
+			if !ok {
+				return nil
+			}
+			w.event(event)
+		case err, ok := <-notify.Errors:
+			if !ok {
+				return nil
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// Some events were lost, look for changes in the whole folder
+				logger.Warn("Too many events, rescanning folder", "path", root)
+				if err := w.add(root); err != nil {
+					logger.Error("Error rescanning folder", "path", root, "error", err)
+				}
+				continue
+			}
+			logger.Error("Error watching folder", "path", root, "error", err)
+		case written := <-w.done:
+			w.handled(written)
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// add watches a folder and its subfolders and marks their files as pending,
+// skipping the subfolders not processed
+func (w *watcher) add(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// The folder may be gone already
+			w.logger.Debug("Could not walk", "path", path, "error", err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != w.root && w.opts.SkipDir != nil && w.opts.SkipDir(path) {
+				w.logger.Debug("Not watching", "path", path)
+				return fs.SkipDir
+			}
+			if err := w.notify.Add(path); err != nil {
+				return err
+			}
+			return nil
+		}
+		w.touch(path)
+		return nil
+	})
+}
+
+// event records a change of the watched folders
+func (w *watcher) event(event fsnotify.Event) {
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return
+	}
+	info, err := os.Lstat(event.Name)
+	if err != nil {
+		return
+	}
+	if info.IsDir() {
+		if event.Has(fsnotify.Create) {
+			if err := w.add(event.Name); err != nil {
+				w.logger.Error("Error watching folder", "path", event.Name, "error", err)
+			}
+		}
+		return
+	}
+	w.touch(event.Name)
+}
+
+// touch marks a file as pending, resetting the time it has been stable.
+// Files just written by handle are not.
+func (w *watcher) touch(path string) {
+	if until, ok := w.written[path]; ok && w.now().Before(until) {
+		return
+	}
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	w.pending[path] = &pending{size: info.Size(), modTime: info.ModTime(), since: w.now()}
+}
+
+// check hands the pending files that have been stable long enough to
+// handle, unless it is still running with the previous ones
+func (w *watcher) check() {
+	now := w.now()
+	for path, until := range w.written {
+		if !now.Before(until) {
+			delete(w.written, path)
+		}
+	}
+	if w.busy {
+		return
+	}
+	candidates := []string{}
+	for path, p := range w.pending {
+		info, err := os.Lstat(path)
+		if err != nil {
+			delete(w.pending, path)
+			continue
+		}
+		if info.Size() != p.size || !info.ModTime().Equal(p.modTime) {
+			p.size, p.modTime, p.since = info.Size(), info.ModTime(), now
+			continue
+		}
+		if now.Sub(p.since) >= w.opts.Stable {
+			candidates = append(candidates, path)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	writing := w.writers(candidates)
+	ready := []string{}
+	for _, path := range candidates {
+		if writing[path] {
+			w.pending[path].since = now
+			continue
+		}
+		delete(w.pending, path)
+		ready = append(ready, path)
+	}
+	if len(ready) == 0 {
+		return
+	}
+	sort.Strings(ready)
+	w.logger.Debug("Files ready", "count", len(ready))
+	w.busy = true
+	go func() {
+		w.done <- w.handle(ready)
+	}()
+}
+
+// handled ignores the events of the paths written by handle for the stable
+// interval once it returns
+func (w *watcher) handled(written []string) {
+	w.busy = false
+	until := w.now().Add(w.opts.Stable)
+	for _, path := range written {
+		w.written[path] = until
+		// Their events may have been recorded while handle was running
+		delete(w.pending, path)
+	}
+}
+
+// wait waits for the running handle, if any, to return
+func (w *watcher) wait() {
+	if w.busy {
+		w.handled(<-w.done)
+	}
+}
@@ -0,0 +1,324 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestWatcher returns a watcher without notifications, with a clock that
+// only moves when the test advances it
+func newTestWatcher(root string, writing map[string]bool) (*watcher, *time.Time, *[][]string) {
+	now := time.Date(2022, 10, 3, 10, 0, 0, 0, time.UTC)
+	handled := [][]string{}
+	w := &watcher{
+		root:    root,
+		opts:    Options{Stable: time.Second},
+		logger:  logging.Discard(),
+		pending: map[string]*pending{},
+		written: map[string]time.Time{},
+		done:    make(chan []string, 1),
+		now:     func() time.Time { return now },
+		handle:  func(paths []string) []string { handled = append(handled, paths); return nil },
+		writers: func(paths []string) map[string]bool { return writing },
+	}
+	return w, &now, &handled
+}
+
+// checkAndWait checks the pending files and waits for handle, as Run does
+func checkAndWait(w *watcher) {
+	w.check()
+	w.wait()
+}
+
+func TestCheck_StableFiles(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a.jpeg")
+	b := filepath.Join(root, "b.jpeg")
+	assert.NoError(t, os.WriteFile(a, []byte("a"), 0644))
+	assert.NoError(t, os.WriteFile(b, []byte("b"), 0644))
+
+	w, now, handled := newTestWatcher(root, map[string]bool{})
+	w.touch(a)
+	w.touch(b)
+
+	// Nothing is handled before the files are stable
+	checkAndWait(w)
+	assert.Empty(t, *handled)
+
+	// A file that grows is stable again only after the interval
+	*now = now.Add(500 * time.Millisecond)
+	assert.NoError(t, os.WriteFile(b, []byte("bigger"), 0644))
+	checkAndWait(w)
+	*now = now.Add(600 * time.Millisecond)
+	checkAndWait(w)
+	assert.Equal(t, [][]string{{a}}, *handled)
+
+	*now = now.Add(time.Second)
+	checkAndWait(w)
+	assert.Equal(t, [][]string{{a}, {b}}, *handled)
+	assert.Empty(t, w.pending)
+}
+
+func TestCheck_OpenForWriting(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a.jpeg")
+	assert.NoError(t, os.WriteFile(a, []byte("a"), 0644))
+
+	writing := map[string]bool{a: true}
+	w, now, handled := newTestWatcher(root, writing)
+	w.touch(a)
+	*now = now.Add(time.Second)
+	checkAndWait(w)
+	assert.Empty(t, *handled)
+
+	// Once the writer closes the file it has to be stable for the interval again
+	delete(writing, a)
+	*now = now.Add(500 * time.Millisecond)
+	checkAndWait(w)
+	assert.Empty(t, *handled)
+	*now = now.Add(500 * time.Millisecond)
+	checkAndWait(w)
+	assert.Equal(t, [][]string{{a}}, *handled)
+}
+
+func TestCheck_RemovedFiles(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a.jpeg")
+	assert.NoError(t, os.WriteFile(a, []byte("a"), 0644))
+
+	w, now, handled := newTestWatcher(root, map[string]bool{})
+	w.touch(a)
+	assert.NoError(t, os.Remove(a))
+	*now = now.Add(time.Second)
+	checkAndWait(w)
+	assert.Empty(t, *handled)
+	assert.Empty(t, w.pending)
+}
+
+func TestCheck_WrittenFiles(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a.jpeg")
+	renamed := filepath.Join(root, "2019_08_05_14_12_13.jpeg")
+	assert.NoError(t, os.WriteFile(a, []byte("a"), 0644))
+
+	w, now, handled := newTestWatcher(root, map[string]bool{})
+	w.handle = func(paths []string) []string {
+		*handled = append(*handled, paths)
+		assert.NoError(t, os.Rename(a, renamed))
+		return []string{renamed}
+	}
+	w.touch(a)
+	*now = now.Add(time.Second)
+	w.check()
+	written := <-w.done
+	assert.Equal(t, [][]string{{a}}, *handled)
+
+	// The events of the new name recorded while handle was running are
+	// dropped, the later ones are ignored for the stable interval
+	w.touch(renamed)
+	assert.Len(t, w.pending, 1)
+	w.handled(written)
+	assert.Empty(t, w.pending)
+	w.touch(renamed)
+	assert.Empty(t, w.pending)
+	*now = now.Add(time.Second)
+	checkAndWait(w)
+	assert.Empty(t, w.written)
+	w.touch(renamed)
+	assert.Len(t, w.pending, 1)
+}
+
+func TestCheck_Busy(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a.jpeg")
+	b := filepath.Join(root, "b.jpeg")
+	assert.NoError(t, os.WriteFile(a, []byte("a"), 0644))
+	assert.NoError(t, os.WriteFile(b, []byte("b"), 0644))
+
+	w, now, _ := newTestWatcher(root, map[string]bool{})
+	release := make(chan bool)
+	handled := make(chan []string, 2)
+	w.handle = func(paths []string) []string {
+		handled <- paths
+		<-release
+		return nil
+	}
+	w.touch(a)
+	*now = now.Add(time.Second)
+	w.check()
+	assert.Equal(t, []string{a}, <-handled)
+
+	// Files stable while handle runs wait for it to return
+	w.touch(b)
+	*now = now.Add(time.Second)
+	w.check()
+	assert.Len(t, w.pending, 1)
+	release <- true
+	w.wait()
+	w.check()
+	assert.Equal(t, []string{b}, <-handled)
+	release <- true
+	w.wait()
+	assert.Empty(t, w.pending)
+}
+
+func TestOpenForWriting(t *testing.T) {
+	if _, err := os.Stat("/proc/self/fdinfo"); err != nil {
+		t.Skip("no /proc in this platform")
+	}
+	root := t.TempDir()
+	path := filepath.Join(root, "a.jpeg")
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	assert.True(t, openForWriting([]string{path})[path])
+
+	assert.NoError(t, file.Close())
+	assert.False(t, openForWriting([]string{path})[path])
+}
+
+func TestRun(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "existing.jpeg")
+	assert.NoError(t, os.WriteFile(existing, []byte("a"), 0644))
+
+	var mu sync.Mutex
+	handled := map[string]bool{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, root, Options{Stable: 50 * time.Millisecond}, func(paths []string) []string {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, p := range paths {
+				handled[p] = true
+			}
+			return nil
+		})
+	}()
+
+	// Files in new folders are handled too
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0755))
+	time.Sleep(50 * time.Millisecond)
+	created := filepath.Join(root, "sub", "created.jpeg")
+	assert.NoError(t, os.WriteFile(created, []byte("b"), 0644))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled[existing] && handled[created]
+	}, 5*time.Second, 20*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestRun_Renames(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.jpeg", "b.jpeg"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(name), 0644))
+	}
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, root, Options{Stable: 50 * time.Millisecond}, func(paths []string) []string {
+			mu.Lock()
+			defer mu.Unlock()
+			renamed := []string{}
+			for _, p := range paths {
+				handled[filepath.Base(p)]++
+				newPath := filepath.Join(filepath.Dir(p), "renamed-"+filepath.Base(p))
+				assert.NoError(t, os.Rename(p, newPath))
+				renamed = append(renamed, newPath)
+			}
+			return renamed
+		})
+	}()
+
+	// Each file is handled once, its new name is not handled again
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 2
+	}, 5*time.Second, 20*time.Millisecond)
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, map[string]int{"a.jpeg": 1, "b.jpeg": 1}, handled)
+}
+
+func TestRun_SkipDir(t *testing.T) {
+	root := t.TempDir()
+	skipped := filepath.Join(root, "skipped")
+	assert.NoError(t, os.MkdirAll(skipped, 0755))
+	existing := filepath.Join(skipped, "existing.jpeg")
+	assert.NoError(t, os.WriteFile(existing, []byte("a"), 0644))
+
+	var mu sync.Mutex
+	handled := map[string]bool{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	opts := Options{Stable: 50 * time.Millisecond, SkipDir: func(dir string) bool { return filepath.Base(dir) == "skipped" }}
+	go func() {
+		done <- Run(ctx, root, opts, func(paths []string) []string {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, p := range paths {
+				handled[p] = true
+			}
+			return nil
+		})
+	}()
+
+	// Skipped folders are not watched, neither when created later
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, os.WriteFile(filepath.Join(skipped, "created.jpeg"), []byte("b"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "sub", "skipped"), 0755))
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "sub", "skipped", "created.jpeg"), []byte("c"), 0644))
+	created := filepath.Join(root, "sub", "created.jpeg")
+	assert.NoError(t, os.WriteFile(created, []byte("d"), 0644))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled[created]
+	}, 5*time.Second, 20*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, map[string]bool{created: true}, handled)
+}
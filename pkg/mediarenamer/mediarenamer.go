@@ -0,0 +1,448 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package mediarenamer renames photos and videos after the date found in
+// their metadata. It is the engine behind the media-renamer command:
+//
+//	r, err := mediarenamer.New(mediarenamer.WithCollisionPolicy(mediarenamer.CollisionSkip))
+//	if err != nil {
+//		return err
+//	}
+//	defer r.Close()
+//
+//	plan, err := r.Plan(ctx, "/photos")
+//	...
+//	results, err := r.Apply(ctx, plan)
+package mediarenamer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/barasher/go-exiftool"
+	"github.com/lluissm/media-renamer/internal/config"
+	"github.com/lluissm/media-renamer/internal/filesystem"
+	"github.com/lluissm/media-renamer/internal/process"
+)
+
+// Renamer plans and executes the renames of media files. It must not be
+// used concurrently.
+type Renamer struct {
+	// config, if set, is used for all paths. Otherwise the configuration
+	// layers of each processed path are resolved, see config.Layers.
+	config           *config.Config
+	customConfigPath string
+	userConfig       bool
+	hooks            bool
+	configs          map[string]*config.Config
+
+	extractor process.Extractor
+	// exiftool is the extractor started by the renamer, closed by Close
+	exiftool *exiftool.Exiftool
+
+	fs         Filesystem
+	logger     *slog.Logger
+	observers  []Observer
+	collision  CollisionPolicy
+	exclude    []string
+	maxDepth   int
+	trustNames bool
+	times      Times
+	metadata   bool
+	backup     bool
+	shift      time.Duration
+	shifted    bool
+	cache      Cache
+	progress   *Progress
+
+	syncRefs []syncRef
+	// syncRules are the shift rules of syncRefs, computed on first use
+	syncRules []config.ShiftRule
+	syncErr   error
+}
+
+// syncRef is a pair of photos taken at the same time by two cameras
+type syncRef struct {
+	reference, target string
+}
+
+// Request selects the files processed by Renamer.Process
+type Request struct {
+	// Paths are the folders and files to process
+	Paths []string
+	// Root, if set, is a folder containing all Paths, which must be files.
+	// They are then filtered as if Root was walked, e.g., excluded files
+	// or files deeper than the maximum depth are skipped.
+	Root string
+	// DryRun only plans the renames, ready to be passed to Renamer.Apply
+	DryRun bool
+}
+
+// New returns a Renamer with the given options. By default it uses the
+// default and project configuration of each processed path, extracts the
+// metadata with an exiftool started on first use and renames files in the
+// OS filesystem. The user config file and the hooks are only used with
+// WithUserConfig and WithHooks.
+func New(options ...Option) (*Renamer, error) {
+	r := &Renamer{
+		configs:   map[string]*config.Config{},
+		fs:        filesystem.OS{},
+		collision: CollisionSuffix,
+	}
+	for _, option := range options {
+		if err := option(r); err != nil {
+			return nil, err
+		}
+	}
+	if r.shifted && !r.metadata {
+		return nil, errors.New("writing the shifted dates requires writing the metadata")
+	}
+	return r, nil
+}
+
+// Plan returns the renames of the files in paths without executing them
+func (r *Renamer) Plan(ctx context.Context, paths ...string) ([]Result, error) {
+	return r.collect(ctx, Request{Paths: paths, DryRun: true})
+}
+
+// Rename renames the files in paths and returns their results
+func (r *Renamer) Rename(ctx context.Context, paths ...string) ([]Result, error) {
+	return r.collect(ctx, Request{Paths: paths})
+}
+
+// collect processes a request and returns all its results
+func (r *Renamer) collect(ctx context.Context, req Request) ([]Result, error) {
+	results := []Result{}
+	err := r.Process(ctx, req, func(res Result) {
+		results = append(results, res)
+	})
+	return results, err
+}
+
+// Process processes the files of a request and calls fn with the result of
+// each of them as soon as it is known. Paths that cannot be processed,
+// e.g., because of an invalid configuration, do not stop the other ones and
+// their errors are returned together. Once ctx is done it stops after the
+// file being renamed and returns the context error.
+func (r *Renamer) Process(ctx context.Context, req Request, fn func(Result)) error {
+	et, err := r.getExtractor()
+	if err != nil {
+		return err
+	}
+	opts := r.options(req.DryRun, fn)
+	if opts.Metadata, err = r.metadataWriter(et); err != nil {
+		return err
+	}
+
+	if req.Root != "" {
+		cfg, err := r.getConfig(req.Root)
+		if err != nil {
+			return fmt.Errorf("%s: %w", req.Root, err)
+		}
+		return process.Files(ctx, et, cfg, req.Root, req.Paths, opts)
+	}
+
+	targets, err := process.Targets(r.fs, req.Paths)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, target := range targets {
+		cfg, err := r.getConfig(target.Root)
+		if err == nil && target.Files != nil {
+			err = process.Files(ctx, et, cfg, target.Root, target.Files, opts)
+		} else if err == nil {
+			err = process.Folder(ctx, et, cfg, target.Root, opts)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.Root, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Apply executes the planned renames of a dry run, with the configured
+// hooks, and returns the final results. The collision policy is applied
+// again in case a new name was taken since it was planned. Results that are
+// not planned are returned as they are, as are the planned ones left once
+// ctx is done.
+func (r *Renamer) Apply(ctx context.Context, plan []Result) ([]Result, error) {
+	// The files were already counted in the progress when planned
+	opts := r.options(false, nil)
+	opts.Observer = r.observer(nil, false)
+	et, err := r.getExtractor()
+	if err == nil {
+		opts.Metadata, err = r.metadataWriter(et)
+	}
+	if err != nil {
+		return plan, err
+	}
+
+	results := make([]Result, 0, len(plan))
+	configs := []*config.Config{}
+	applied := map[*config.Config][]Result{}
+	var errs []error
+	for _, res := range plan {
+		if res.Status == StatusPlanned && ctx.Err() == nil {
+			cfg, err := r.getConfig(res.Path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", res.Path, err))
+				results = append(results, res)
+				continue
+			}
+			res = process.Execute(res, cfg, opts)
+			if _, ok := applied[cfg]; !ok {
+				configs = append(configs, cfg)
+			}
+			applied[cfg] = append(applied[cfg], res)
+		}
+		results = append(results, res)
+	}
+	for _, cfg := range configs {
+		if err := process.PostRun(cfg, applied[cfg]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	return results, errors.Join(errs...)
+}
+
+// Discover returns the number of files in paths that would be processed
+func (r *Renamer) Discover(ctx context.Context, paths ...string) (int, error) {
+	targets, err := process.Targets(r.fs, paths)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, target := range targets {
+		cfg, err := r.getConfig(target.Root)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", target.Root, err)
+		}
+		var count int
+		if target.Files != nil {
+			count, err = process.DiscoverFiles(ctx, cfg, target.Root, target.Files, r.options(true, nil))
+		} else {
+			count, err = process.Discover(ctx, cfg, target.Root, r.options(true, nil))
+		}
+		total += count
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Inspect calls fn with the details of how the date of each file in paths
+// is obtained, listing all the date-like tags in the metadata if allDates.
+// As Process, it goes on with the other paths if one cannot be inspected.
+func (r *Renamer) Inspect(ctx context.Context, allDates bool, fn func(Inspection), paths ...string) error {
+	et, err := r.getExtractor()
+	if err != nil {
+		return err
+	}
+	roots, err := process.Roots(paths)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, root := range roots {
+		cfg, err := r.getConfig(root)
+		if err == nil {
+			err = process.Inspect(ctx, et, cfg, root, r.options(true, nil), allDates, fn)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", root, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DirSkipper returns a function telling whether processing root skips a
+// folder under it, because it is excluded or ignored, e.g., so that a
+// watcher does not watch it
+func (r *Renamer) DirSkipper(root string) (func(dir string) bool, error) {
+	cfg, err := r.getConfig(root)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", root, err)
+	}
+	return process.DirSkipper(cfg, root, r.options(true, nil))
+}
+
+// Close stops the exiftool started by the renamer, if any
+func (r *Renamer) Close() error {
+	if r.exiftool == nil {
+		return nil
+	}
+	err := r.exiftool.Close()
+	r.exiftool = nil
+	return err
+}
+
+// options returns the process options of the renamer
+func (r *Renamer) options(dryRun bool, fn func(Result)) process.Options {
+	return process.Options{
+		Observer:     r.observer(fn, true),
+		Exclude:      r.exclude,
+		MaxDepth:     r.maxDepth,
+		FS:           r.fs,
+		DryRun:       dryRun,
+		TrustNames:   r.trustNames,
+		Cache:        r.cache,
+		Collision:    r.collision,
+		Times:        r.times,
+		Shift:        r.shift,
+		WriteShifted: r.shifted,
+	}
+}
+
+// observer returns the observers of the renamer followed by fn, if set.
+// The progress is only counted while processing, applying a plan must not
+// count its files again.
+func (r *Renamer) observer(fn func(Result), counting bool) Observer {
+	observers := Observers{}
+	if r.logger != nil {
+		observers = append(observers, &process.LogObserver{Logger: r.logger})
+	}
+	if counting && r.progress != nil {
+		observers = append(observers, r.progress)
+	}
+	observers = append(observers, r.observers...)
+	if fn != nil {
+		observers = append(observers, ResultFunc(fn))
+	}
+	return observers
+}
+
+// getExtractor returns the extractor of the renamer, starting exiftool the
+// first time if none was provided
+func (r *Renamer) getExtractor() (process.Extractor, error) {
+	if r.extractor != nil {
+		return r.extractor, nil
+	}
+	if r.exiftool == nil {
+		var options []func(*exiftool.Exiftool) error
+		if r.backup {
+			options = append(options, exiftool.BackupOriginal())
+		}
+		et, err := exiftool.NewExiftool(options...)
+		if err != nil {
+			return nil, fmt.Errorf("could not start exiftool: %w", err)
+		}
+		r.exiftool = et
+	}
+	return r.exiftool, nil
+}
+
+// metadataWriter returns the extractor et as the writer of the metadata of
+// the renamed files, nil if it is not written
+func (r *Renamer) metadataWriter(et process.Extractor) (process.MetadataWriter, error) {
+	if !r.metadata {
+		return nil, nil
+	}
+	w, ok := et.(process.MetadataWriter)
+	if !ok {
+		return nil, errors.New("the extractor cannot write metadata")
+	}
+	return w, nil
+}
+
+// getConfig returns the configuration of path, with the shift rules of the
+// sync references, sharing it between paths with the same project config file
+func (r *Renamer) getConfig(path string) (*config.Config, error) {
+	projectConfigPath := ""
+	if r.config == nil {
+		projectConfigPath, _ = config.FindProjectConfig(path)
+	}
+	if cfg, ok := r.configs[projectConfigPath]; ok {
+		return cfg, nil
+	}
+
+	cfg, err := r.resolveConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.syncRefs) > 0 {
+		rules, err := r.getSyncRules()
+		if err != nil {
+			return nil, err
+		}
+		cfg = cfg.WithShifts(rules...)
+	}
+	if !r.hooks {
+		cfg = cfg.WithoutHooks()
+	}
+	r.configs[projectConfigPath] = cfg
+	return cfg, nil
+}
+
+// resolveConfig returns the configuration of path as given or resolved from
+// its configuration layers
+func (r *Renamer) resolveConfig(path string) (*config.Config, error) {
+	if r.config != nil {
+		return r.config, nil
+	}
+	layers, err := config.Layers(path, r.customConfigPath, r.userConfig)
+	if err != nil {
+		return nil, err
+	}
+	return config.Resolve(layers...)
+}
+
+// getSyncRules returns the shift rules that put the cameras of the sync
+// references on a common clock, computing them the first time
+func (r *Renamer) getSyncRules() ([]config.ShiftRule, error) {
+	if r.syncRules != nil || r.syncErr != nil {
+		return r.syncRules, r.syncErr
+	}
+	et, err := r.getExtractor()
+	if err != nil {
+		return nil, err
+	}
+	rules := []config.ShiftRule{}
+	for _, ref := range r.syncRefs {
+		cfg, err := r.resolveConfig(ref.reference)
+		if err == nil {
+			var rule config.ShiftRule
+			rule, err = process.SyncRule(et, cfg, ref.reference, ref.target)
+			rules = append(rules, rule)
+		}
+		if err != nil {
+			r.syncErr = fmt.Errorf("could not sync %s and %s: %w", ref.reference, ref.target, err)
+			return nil, r.syncErr
+		}
+	}
+	r.syncRules = rules
+	return rules, nil
+}
@@ -0,0 +1,339 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package mediarenamer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/barasher/go-exiftool"
+	"github.com/stretchr/testify/assert"
+)
+
+// extractorMock returns the same date for all files
+type extractorMock struct{}
+
+func (e *extractorMock) ExtractMetadata(files ...string) []exiftool.FileMetadata {
+	res := []exiftool.FileMetadata{}
+	for _, f := range files {
+		res = append(res, exiftool.FileMetadata{File: f, Fields: map[string]interface{}{"CreateDate": "2019:08:05 14:12:13"}})
+	}
+	return res
+}
+
+const newName = "2019_08_05_14_12_13.jpeg"
+
+// createFiles creates empty files inside root
+func createFiles(t *testing.T, root string, files ...string) {
+	for _, f := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, f), nil, 0644))
+	}
+}
+
+func newRenamer(t *testing.T, options ...Option) *Renamer {
+	options = append([]Option{WithExtractor(&extractorMock{}), WithConfig(nil)}, options...)
+	r, err := New(options...)
+	assert.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, r.Close()) })
+	return r
+}
+
+func TestPlanAndApply(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "a.jpeg", "b.jpeg")
+	r := newRenamer(t)
+
+	plan, err := r.Plan(context.Background(), root)
+	assert.NoError(t, err)
+	assert.Len(t, plan, 2)
+	assert.Equal(t, StatusPlanned, plan[0].Status)
+	assert.Equal(t, filepath.Join(root, newName), plan[0].NewPath)
+	assert.Equal(t, filepath.Join(root, "2019_08_05_14_12_13_1.jpeg"), plan[1].NewPath)
+	assert.FileExists(t, filepath.Join(root, "a.jpeg"))
+
+	results, err := r.Apply(context.Background(), plan)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusRenamed, results[0].Status)
+	assert.Equal(t, StatusRenamed, results[1].Status)
+	assert.FileExists(t, filepath.Join(root, newName))
+	assert.FileExists(t, filepath.Join(root, "2019_08_05_14_12_13_1.jpeg"))
+
+	// Nothing is applied once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = r.Apply(ctx, plan)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, StatusPlanned, results[0].Status)
+}
+
+func TestRename_Collision(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "a.jpeg", newName)
+	r := newRenamer(t, WithCollisionPolicy(CollisionSkip))
+
+	results, err := r.Rename(context.Background(), filepath.Join(root, "a.jpeg"))
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, ClassCollision, results[0].ErrorClass)
+	assert.ErrorIs(t, results[0].Err(), ErrCollision)
+	assert.FileExists(t, filepath.Join(root, "a.jpeg"))
+
+	_, err = New(WithCollisionPolicy("rename"))
+	assert.Error(t, err)
+}
+
+func TestProcess(t *testing.T) {
+	// The files only exist in memory
+	root := t.TempDir()
+	fs := NewMemoryFilesystem()
+	for _, f := range []string{"a.jpeg", "b.txt"} {
+		assert.NoError(t, fs.WriteFile(filepath.Join(root, f), nil, 0644))
+	}
+	r := newRenamer(t, WithFilesystem(fs), WithExclude("b.*"))
+
+	// Files of a root are filtered as if it was walked
+	results := []Result{}
+	req := Request{Root: root, Paths: []string{filepath.Join(root, "a.jpeg"), filepath.Join(root, "b.txt")}}
+	assert.NoError(t, r.Process(context.Background(), req, func(res Result) { results = append(results, res) }))
+	assert.Len(t, results, 1)
+	assert.Equal(t, StatusRenamed, results[0].Status)
+	_, err := fs.Stat(filepath.Join(root, newName))
+	assert.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(root, newName))
+
+	count, err := r.Discover(context.Background(), root)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+// writerMock is an extractor that records the metadata written
+type writerMock struct {
+	extractorMock
+	written []exiftool.FileMetadata
+}
+
+func (w *writerMock) WriteMetadata(fileMetadata []exiftool.FileMetadata) {
+	w.written = append(w.written, fileMetadata...)
+}
+
+func TestMetadataWriting(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "a.jpeg")
+
+	// The extractor must be able to write metadata
+	r := newRenamer(t, WithMetadataWriting(false))
+	_, err := r.Plan(context.Background(), root)
+	assert.Error(t, err)
+
+	writer := &writerMock{}
+	r = newRenamer(t, WithExtractor(writer), WithMetadataWriting(false))
+	plan, err := r.Plan(context.Background(), root)
+	assert.NoError(t, err)
+	assert.Empty(t, writer.written)
+	results, err := r.Apply(context.Background(), plan)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusRenamed, results[0].Status)
+	assert.Len(t, writer.written, 1)
+	assert.Equal(t, filepath.Join(root, newName), writer.written[0].File)
+	value, err := writer.written[0].GetString("XMP-xmpMM:PreservedFileName")
+	assert.NoError(t, err)
+	assert.Equal(t, "a.jpeg", value)
+}
+
+func TestShift(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "a.jpeg")
+
+	r := newRenamer(t, WithShift(-90*time.Minute))
+	plan, err := r.Plan(context.Background(), root)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "2019_08_05_12_42_13.jpeg"), plan[0].NewPath)
+	assert.Equal(t, "-1h30m0s", plan[0].Shift)
+
+	// Writing the shifted dates requires writing the metadata
+	_, err = New(WithExtractor(&extractorMock{}), WithConfig(nil), WithShiftWriting())
+	assert.Error(t, err)
+}
+
+// camerasMock returns the metadata of a Canon camera, with a clock two
+// minutes ahead, for the files with "canon" in their name and of a Sony one
+// for the rest
+type camerasMock struct{}
+
+func (camerasMock) ExtractMetadata(files ...string) []exiftool.FileMetadata {
+	res := []exiftool.FileMetadata{}
+	for _, f := range files {
+		fields := map[string]interface{}{"CreateDate": "2019:08:05 14:12:13", "Make": "Sony"}
+		if strings.Contains(f, "canon") {
+			fields = map[string]interface{}{"CreateDate": "2019:08:05 14:14:13", "Make": "Canon"}
+		}
+		res = append(res, exiftool.FileMetadata{File: f, Fields: fields})
+	}
+	return res
+}
+
+func TestSyncReference(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "sony.jpeg", "canon.jpeg", "canon2.jpeg")
+	sync := WithSyncReference(filepath.Join(root, "sony.jpeg"), filepath.Join(root, "canon.jpeg"))
+
+	r := newRenamer(t, WithExtractor(camerasMock{}), sync)
+	results, err := r.Rename(context.Background(), root)
+	assert.NoError(t, err)
+	for _, res := range results {
+		assert.Equal(t, StatusRenamed, res.Status, res.Path)
+	}
+	assert.FileExists(t, filepath.Join(root, newName))
+	assert.FileExists(t, filepath.Join(root, "2019_08_05_14_12_13_1.jpeg"))
+	assert.FileExists(t, filepath.Join(root, "2019_08_05_14_12_13_2.jpeg"))
+
+	// Cameras without make, model or serial number cannot be synced
+	r = newRenamer(t, sync)
+	_, err = r.Plan(context.Background(), root)
+	assert.Error(t, err)
+}
+
+func TestProcess_InvalidConfig(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "a.jpeg")
+	other := t.TempDir()
+	createFiles(t, other, "a.jpeg")
+	assert.NoError(t, os.WriteFile(filepath.Join(other, ".media-renamer.yml"), []byte("fileTypes: ["), 0644))
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	r, err := New(WithExtractor(&extractorMock{}))
+	assert.NoError(t, err)
+
+	// The paths with a valid configuration are still processed
+	results, err := r.Plan(context.Background(), root, other)
+	assert.ErrorContains(t, err, other)
+	assert.Len(t, results, 1)
+
+	_, err = New(WithConfig([]byte("fileTypes: [")))
+	assert.Error(t, err)
+}
+
+func TestProcess_ProjectHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook commands are written for sh")
+	}
+	root := t.TempDir()
+	createFiles(t, root, "a.jpeg")
+	marker := filepath.Join(t.TempDir(), "marker")
+	hooks := "hooks:\n  preRename: \"touch " + marker + "\"\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ".media-renamer.yml"), []byte(hooks), 0644))
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	r, err := New(WithExtractor(&extractorMock{}), WithUserConfig(), WithHooks())
+	assert.NoError(t, err)
+
+	// The hooks of a config file found in the processed folder never run
+	_, err = r.Rename(context.Background(), root)
+	assert.ErrorContains(t, err, "hooks")
+	assert.NoFileExists(t, marker)
+	assert.FileExists(t, filepath.Join(root, "a.jpeg"))
+}
+
+func TestProcess_UserConfigHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook commands are written for sh")
+	}
+	marker := filepath.Join(t.TempDir(), "marker")
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	userPath := filepath.Join(xdg, "media-renamer", "config.yml")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(userPath), 0755))
+	hooks := "hooks:\n  preRename: \"touch " + marker + "\"\n"
+	assert.NoError(t, os.WriteFile(userPath, []byte(hooks), 0644))
+
+	// By default neither the user config file nor the hooks are used
+	root := t.TempDir()
+	createFiles(t, root, "a.jpeg")
+	r, err := New(WithExtractor(&extractorMock{}))
+	assert.NoError(t, err)
+	_, err = r.Rename(context.Background(), root)
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(root, newName))
+	assert.NoFileExists(t, marker)
+
+	// The hooks of the user config file need both options
+	root = t.TempDir()
+	createFiles(t, root, "a.jpeg")
+	r, err = New(WithExtractor(&extractorMock{}), WithUserConfig())
+	assert.NoError(t, err)
+	_, err = r.Rename(context.Background(), root)
+	assert.NoError(t, err)
+	assert.NoFileExists(t, marker)
+
+	root = t.TempDir()
+	createFiles(t, root, "a.jpeg")
+	r, err = New(WithExtractor(&extractorMock{}), WithUserConfig(), WithHooks())
+	assert.NoError(t, err)
+	_, err = r.Rename(context.Background(), root)
+	assert.NoError(t, err)
+	assert.FileExists(t, marker)
+}
+
+func TestInspect(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "a.jpeg")
+	r := newRenamer(t)
+
+	inspections := []Inspection{}
+	assert.NoError(t, r.Inspect(context.Background(), false, func(ins Inspection) { inspections = append(inspections, ins) }, root))
+	assert.Len(t, inspections, 1)
+	assert.Equal(t, "CreateDate", inspections[0].Winner)
+	assert.Equal(t, newName, inspections[0].NewName)
+}
+
+// skipObserver records the skipped files
+type skipObserver struct {
+	BaseObserver
+	skipped map[string]SkipReason
+}
+
+func (o *skipObserver) FileSkipped(path string, reason SkipReason) {
+	o.skipped[filepath.Base(path)] = reason
+}
+
+func TestObserver(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "a.jpeg", "b.txt")
+	observer := &skipObserver{skipped: map[string]SkipReason{}}
+	progress := &Progress{}
+	r := newRenamer(t, WithObserver(observer), WithProgress(progress))
+
+	plan, err := r.Plan(context.Background(), root)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]SkipReason{"b.txt": SkipUnsupported}, observer.skipped)
+	assert.Equal(t, int64(1), progress.Processed.Load())
+
+	// Applying the plan does not count the files again
+	_, err = r.Apply(context.Background(), plan)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), progress.Processed.Load())
+}
@@ -0,0 +1,219 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package mediarenamer
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/lluissm/media-renamer/internal/config"
+	"github.com/lluissm/media-renamer/internal/process"
+)
+
+// Option configures a Renamer
+type Option func(*Renamer) error
+
+// WithConfigFile adds a config file on top of the default, user and project
+// configuration of each processed path
+func WithConfigFile(path string) Option {
+	return func(r *Renamer) error {
+		r.customConfigPath = path
+		return nil
+	}
+}
+
+// WithUserConfig uses the user config file,
+// $XDG_CONFIG_HOME/media-renamer/config.yml, between the default and the
+// project configuration of each processed path
+func WithUserConfig() Option {
+	return func(r *Renamer) error {
+		r.userConfig = true
+		return nil
+	}
+}
+
+// WithHooks runs the shell commands of the hooks of the configuration
+// around the renames. Without it, hooks are ignored.
+func WithHooks() Option {
+	return func(r *Renamer) error {
+		r.hooks = true
+		return nil
+	}
+}
+
+// WithConfig uses the default configuration merged with the given yaml
+// document for all paths, ignoring the user and project config files
+func WithConfig(yaml []byte) Option {
+	return func(r *Renamer) error {
+		cfg, err := config.Resolve(config.DefaultLayer(), config.Layer{Source: "WithConfig", Bytes: yaml})
+		if err != nil {
+			return err
+		}
+		r.config = cfg
+		return nil
+	}
+}
+
+// WithExtractor extracts the metadata of the files with et instead of
+// starting exiftool. The caller remains responsible for closing it.
+func WithExtractor(et Extractor) Option {
+	return func(r *Renamer) error {
+		r.extractor = et
+		return nil
+	}
+}
+
+// WithFilesystem walks and renames the files in fs
+func WithFilesystem(fs Filesystem) Option {
+	return func(r *Renamer) error {
+		r.fs = fs
+		return nil
+	}
+}
+
+// WithLogger logs the events of the processing to logger: renamed files at
+// info level, files that could not be renamed at warn level and the details
+// at debug level
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Renamer) error {
+		r.logger = logger
+		return nil
+	}
+}
+
+// WithObserver notifies observer at each stage of the processing of every
+// file. It can be repeated, observers are notified in order.
+func WithObserver(observer Observer) Option {
+	return func(r *Renamer) error {
+		r.observers = append(r.observers, observer)
+		return nil
+	}
+}
+
+// WithCollisionPolicy decides what happens when a new name is taken by
+// another file, CollisionSuffix by default
+func WithCollisionPolicy(policy CollisionPolicy) Option {
+	return func(r *Renamer) error {
+		if _, err := process.ParseCollisionPolicy(string(policy)); err != nil {
+			return err
+		}
+		r.collision = policy
+		return nil
+	}
+}
+
+// WithExclude excludes the files matching the glob patterns on top of the
+// configured ones
+func WithExclude(patterns ...string) Option {
+	return func(r *Renamer) error {
+		r.exclude = append(r.exclude, patterns...)
+		return nil
+	}
+}
+
+// WithMaxDepth only processes the files up to the given depth, 1 being the
+// files directly in a processed folder
+func WithMaxDepth(depth int) Option {
+	return func(r *Renamer) error {
+		r.maxDepth = depth
+		return nil
+	}
+}
+
+// WithTrustNames skips the files whose name already follows the naming
+// scheme without extracting their metadata
+func WithTrustNames() Option {
+	return func(r *Renamer) error {
+		r.trustNames = true
+		return nil
+	}
+}
+
+// WithTimes sets the timestamps selected by times of the renamed files to
+// their date
+func WithTimes(times Times) Option {
+	return func(r *Renamer) error {
+		r.times = times
+		return nil
+	}
+}
+
+// WithMetadataWriting writes the original name of the renamed files to
+// their metadata, and the standard date fields they lack when their date
+// comes from another field. It is written by exiftool, or by the extractor
+// given with WithExtractor if it implements MetadataWriter, overwriting the
+// files unless backup, which keeps their original as NAME_original.
+// The cache is not used then.
+func WithMetadataWriting(backup bool) Option {
+	return func(r *Renamer) error {
+		r.metadata = true
+		r.backup = backup
+		return nil
+	}
+}
+
+// WithShift adds offset to the date of every file, on top of the shift
+// rules of the configuration, e.g., to fix a camera with a wrong clock
+func WithShift(offset time.Duration) Option {
+	return func(r *Renamer) error {
+		r.shift = offset
+		return nil
+	}
+}
+
+// WithShiftWriting writes the shifted dates to the metadata of the renamed
+// files, replacing the ones of the camera. It requires WithMetadataWriting.
+func WithShiftWriting() Option {
+	return func(r *Renamer) error {
+		r.shifted = true
+		return nil
+	}
+}
+
+// WithSyncReference puts the files of the camera that took target on the
+// clock of the camera that took reference, two photos taken at the same
+// time. The cameras are told apart by the make, model and serial number in
+// their metadata. It can be repeated to sync several cameras.
+func WithSyncReference(reference, target string) Option {
+	return func(r *Renamer) error {
+		r.syncRefs = append(r.syncRefs, syncRef{reference, target})
+		return nil
+	}
+}
+
+// WithCache reuses the dates stored in cache for unchanged files
+func WithCache(cache Cache) Option {
+	return func(r *Renamer) error {
+		r.cache = cache
+		return nil
+	}
+}
+
+// WithProgress counts the processed files in progress
+func WithProgress(progress *Progress) Option {
+	return func(r *Renamer) error {
+		r.progress = progress
+		return nil
+	}
+}
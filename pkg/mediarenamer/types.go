@@ -0,0 +1,133 @@
+/* MIT License
+
+Copyright (c) 2022 Lluis Sanchez
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package mediarenamer
+
+import (
+	"github.com/lluissm/media-renamer/internal/filesystem"
+	"github.com/lluissm/media-renamer/internal/process"
+)
+
+type (
+	// Result is the outcome of processing a file. Result.Err returns the
+	// error of a failed file, which wraps one of the Err* sentinel errors.
+	Result = process.Result
+	// Status is the outcome of processing a file
+	Status = process.Status
+	// ErrorClass groups the reasons why a file could not be renamed
+	ErrorClass = process.ErrorClass
+	// CollisionPolicy decides what happens when a new name is taken
+	CollisionPolicy = process.CollisionPolicy
+	// Inspection details how the new name of a file is obtained
+	Inspection = process.Inspection
+	// Extractor extracts the metadata of files, implemented by *exiftool.Exiftool
+	Extractor = process.Extractor
+	// Cache stores the dates extracted from files
+	Cache = process.Cache
+	// CacheEntry is the date of a file stored in a Cache
+	CacheEntry = process.CacheEntry
+	// Progress is an Observer counting the processed files, it can be read
+	// concurrently
+	Progress = process.Progress
+	// Observer is notified at each stage of the processing of a file
+	Observer = process.Observer
+	// BaseObserver ignores every event, to be embedded by observers only
+	// interested in some of them
+	BaseObserver = process.BaseObserver
+	// ResultFunc is an Observer calling a function with the result of each
+	// processed file
+	ResultFunc = process.ResultFunc
+	// Observers notifies several observers, in order
+	Observers = process.Observers
+	// SkipReason tells why a file or folder is not processed
+	SkipReason = process.SkipReason
+	// ResolvedDate is the date found for a file
+	ResolvedDate = process.ResolvedDate
+	// Times selects the timestamps of the files that are set to their date
+	Times = process.Times
+	// MetadataWriter writes metadata tags to files, implemented by *exiftool.Exiftool
+	MetadataWriter = process.MetadataWriter
+	// Filesystem is the filesystem in which files are walked and renamed
+	Filesystem = filesystem.FS
+	// OSFilesystem is the Filesystem of the operating system, the default one
+	OSFilesystem = filesystem.OS
+	// MemoryFilesystem is an in-memory Filesystem, e.g., to try a
+	// configuration or to test without real files
+	MemoryFilesystem = filesystem.Memory
+)
+
+// NewMemoryFilesystem returns an empty in-memory Filesystem. Its files are
+// created with MemoryFilesystem.WriteFile.
+func NewMemoryFilesystem() *MemoryFilesystem {
+	return filesystem.NewMemory()
+}
+
+const (
+	StatusRenamed      = process.StatusRenamed
+	StatusFailed       = process.StatusFailed
+	StatusPlanned      = process.StatusPlanned
+	StatusSkipped      = process.StatusSkipped
+	StatusAlreadyNamed = process.StatusAlreadyNamed
+)
+
+const (
+	ClassMetadata      = process.ClassMetadata
+	ClassUnsupported   = process.ClassUnsupported
+	ClassNoDate        = process.ClassNoDate
+	ClassInvalidDate   = process.ClassInvalidDate
+	ClassRename        = process.ClassRename
+	ClassCollision     = process.ClassCollision
+	ClassVetoed        = process.ClassVetoed
+	ClassTimes         = process.ClassTimes
+	ClassMetadataWrite = process.ClassMetadataWrite
+)
+
+const (
+	SkipExcluded    = process.SkipExcluded
+	SkipIgnored     = process.SkipIgnored
+	SkipUnsupported = process.SkipUnsupported
+	SkipOutside     = process.SkipOutside
+)
+
+const (
+	CollisionSuffix    = process.CollisionSuffix
+	CollisionSkip      = process.CollisionSkip
+	CollisionOverwrite = process.CollisionOverwrite
+)
+
+var (
+	ErrMetadata      = process.ErrMetadata
+	ErrUnsupported   = process.ErrUnsupported
+	ErrNoDate        = process.ErrNoDate
+	ErrInvalidDate   = process.ErrInvalidDate
+	ErrRename        = process.ErrRename
+	ErrCollision     = process.ErrCollision
+	ErrVetoed        = process.ErrVetoed
+	ErrTimes         = process.ErrTimes
+	ErrMetadataWrite = process.ErrMetadataWrite
+)
+
+// ParseCollisionPolicy returns the policy with the given name
+func ParseCollisionPolicy(name string) (CollisionPolicy, error) {
+	return process.ParseCollisionPolicy(name)
+}
@@ -44,6 +44,11 @@ run_version_test() {
     if [[ ! "$output" =~ "version:" ]]; then exit 1; fi
 }
 
+run_version_command_test() {
+    output=$($CMD version)
+    if [[ ! "$output" =~ "version:" ]]; then exit 1; fi
+}
+
 run_test() {
     path=$1
     delete_sample_project
@@ -56,9 +61,9 @@ run_test() {
 run_test "sample-config.yml"
 validate_renaming
 
-# Empty custom config file should not alter any file
+# Empty custom config file is layered on top of the default one
 run_test "empty-config.yml"
-validate_initial_state
+validate_renaming
 
 # If custom config file is not provided it should use the default one
 run_test ""
@@ -67,5 +72,8 @@ validate_renaming
 # Test -version flag
 run_version_test
 
+# Test version subcommand
+run_version_command_test
+
 delete_sample_project
 exit 0